@@ -23,7 +23,7 @@ func main() {
 	logger := internal.NewLogger(config)
 	slog.SetDefault(logger)
 
-	e, err := server.InitServer(config)
+	e, closeServer, err := server.InitServer(config)
 	if err != nil {
 		log.Fatal("Failed to initialize server: ", err)
 	}
@@ -41,6 +41,8 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := e.Shutdown(ctx); err != nil {
-		e.Logger.Fatal(err)
+		e.Logger.Error(err)
 	}
+	// Stop the feed jobs once no more requests can submit them, waiting for their temporary files to be removed
+	closeServer()
 }
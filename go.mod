@@ -3,23 +3,29 @@ module github.com/takatori/skg
 go 1.23.5
 
 require (
+	github.com/google/uuid v1.6.0
+	github.com/ikawaha/kagome-dict v1.1.2
+	github.com/ikawaha/kagome-dict/ipa v1.2.0
+	github.com/ikawaha/kagome-dict/uni v1.2.1
+	github.com/ikawaha/kagome/v2 v2.10.0
 	github.com/kelseyhightower/envconfig v1.4.0
+	github.com/labstack/echo/v4 v4.13.3
 	github.com/morikuni/failure/v2 v2.0.0-20240419002657-2551069d1c86
+	github.com/parquet-go/parquet-go v0.25.1
 	github.com/samber/lo v1.49.1
 )
 
 require (
-	github.com/ikawaha/kagome-dict v1.1.0 // indirect
-	github.com/ikawaha/kagome-dict/ipa v1.2.0 // indirect
-	github.com/ikawaha/kagome/v2 v2.10.0 // indirect
-	github.com/labstack/echo/v4 v4.13.3 // indirect
+	github.com/andybalholm/brotli v1.1.0 // indirect
+	github.com/klauspost/compress v1.17.9 // indirect
 	github.com/labstack/gommon v0.4.2 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/pierrec/lz4/v4 v4.1.21 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasttemplate v1.2.2 // indirect
 	golang.org/x/crypto v0.31.0 // indirect
 	golang.org/x/net v0.33.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
-	golang.org/x/text v0.21.0 // indirect
+	golang.org/x/text v0.23.0 // indirect
 )
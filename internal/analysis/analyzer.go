@@ -0,0 +1,187 @@
+package analysis
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ikawaha/kagome-dict/dict"
+	"github.com/ikawaha/kagome-dict/ipa"
+	"github.com/ikawaha/kagome-dict/uni"
+	"github.com/ikawaha/kagome/v2/tokenizer"
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/errors"
+)
+
+// System dictionaries supported by the Analyzer
+const (
+	DictionaryIPA = "ipa"
+	DictionaryUni = "uni"
+)
+
+// Tokenizer modes supported by the Analyzer
+const (
+	ModeNormal   = "normal"
+	ModeSearch   = "search"
+	ModeExtended = "extended"
+)
+
+// Analyzer extracts terms from Japanese text with the Kagome tokenizer.
+// Loading the dictionary is expensive, so a single Analyzer should be created at startup and shared;
+// it is safe for concurrent use, including while it is being reloaded.
+type Analyzer struct {
+	config   *internal.Config
+	scheme   posScheme
+	profiles map[string]Profile
+	state    atomic.Pointer[tokenizerState]
+}
+
+// tokenizerState is a tokenizer together with the settings it was built with.
+// It is replaced as a whole on reload so that an extraction never mixes dictionaries.
+type tokenizerState struct {
+	tokenizer *tokenizer.Tokenizer
+	mode      tokenizer.TokenizeMode
+	scheme    posScheme
+}
+
+// NewAnalyzer creates a new Analyzer with the system dictionary, tokenizer mode and user dictionary
+// set in the config, loading the dictionaries if needed.
+// The built-in profiles of the system dictionary are always available; profiles with the same name replace them.
+func NewAnalyzer(config *internal.Config, profiles ...Profile) (*Analyzer, error) {
+	scheme, err := schemeFor(config.TokenizerDictionary)
+	if err != nil {
+		return nil, err
+	}
+
+	registered := map[string]Profile{}
+	for _, p := range append(scheme.profiles(), profiles...) {
+		registered[p.Name] = p
+	}
+
+	a := &Analyzer{config: config, scheme: scheme, profiles: registered}
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Reload rebuilds the tokenizer, re-reading the user dictionary.
+// On failure the current tokenizer is kept.
+func (a *Analyzer) Reload() error {
+	mode, err := tokenizeMode(a.config.TokenizerMode)
+	if err != nil {
+		return err
+	}
+
+	opts := []tokenizer.Option{tokenizer.OmitBosEos()}
+	if a.config.UserDictionaryPath != "" {
+		userDict, err := dict.NewUserDict(a.config.UserDictionaryPath)
+		if err != nil {
+			return fmt.Errorf("failed to load user dictionary: %w", err)
+		}
+		opts = append(opts, tokenizer.UserDict(userDict))
+	}
+
+	t, err := tokenizer.New(a.scheme.dict(), opts...)
+	if err != nil {
+		return fmt.Errorf("failed to initialize tokenizer: %w", err)
+	}
+
+	a.state.Store(&tokenizerState{tokenizer: t, mode: mode, scheme: a.scheme})
+	return nil
+}
+
+// Extractor returns an Extractor for the named profile, or for DefaultProfile if the name is empty,
+// and the language of the options. It returns an errors.ErrBadRequest failure if there is no such profile or the options are invalid.
+func (a *Analyzer) Extractor(profile string, opts ExtractOptions) (Extractor, error) {
+	if err := opts.validate(); err != nil {
+		return nil, failure.Translate(
+			err,
+			errors.ErrBadRequest,
+			failure.Field(failure.Message(err.Error())),
+		)
+	}
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	p, ok := a.profiles[profile]
+	if !ok {
+		return nil, failure.New(
+			errors.ErrBadRequest,
+			failure.Field(failure.Messagef("unknown extraction profile %q", profile)),
+			failure.Context{
+				"profile": profile,
+			},
+		)
+	}
+	japanese := &tokenExtractor{state: a.state.Load(), profile: p, options: opts}
+	english := &englishExtractor{profile: p, options: opts}
+	switch opts.Language {
+	case LanguageJapanese:
+		return japanese, nil
+	case LanguageEnglish:
+		return english, nil
+	}
+	return &languageExtractor{
+		extractors: map[string]Extractor{
+			LanguageJapanese: japanese,
+			LanguageEnglish:  english,
+		},
+	}, nil
+}
+
+// ExtractTerms extracts the terms of the Japanese text with the default profile and options
+func (a *Analyzer) ExtractTerms(text string) []string {
+	return (&tokenExtractor{state: a.state.Load(), profile: a.profiles[DefaultProfile]}).Extract(text)
+}
+
+// tokenizeMode parses a tokenizer mode, defaulting to ModeNormal
+func tokenizeMode(mode string) (tokenizer.TokenizeMode, error) {
+	switch mode {
+	case "", ModeNormal:
+		return tokenizer.Normal, nil
+	case ModeSearch:
+		return tokenizer.Search, nil
+	case ModeExtended:
+		return tokenizer.Extended, nil
+	}
+	return 0, fmt.Errorf("unknown tokenizer mode: %q", mode)
+}
+
+// schemeFor returns the POS scheme of a system dictionary, defaulting to DictionaryIPA
+func schemeFor(dictionary string) (posScheme, error) {
+	switch dictionary {
+	case "", DictionaryIPA:
+		return ipaScheme, nil
+	case DictionaryUni:
+		return uniScheme, nil
+	}
+	return posScheme{}, fmt.Errorf("unknown tokenizer dictionary: %q", dictionary)
+}
+
+// posScheme describes the parts of speech of a system dictionary
+type posScheme struct {
+	dict     func() *dict.Dict
+	profiles func() []Profile
+	// compoundNouns and compoundPrefixes are the parts of speech joined into compound nouns,
+	// except compoundExcluded
+	compoundNouns    []string
+	compoundPrefixes []string
+	compoundExcluded []string
+}
+
+var ipaScheme = posScheme{
+	dict:             ipa.Dict,
+	profiles:         ipaProfiles,
+	compoundNouns:    []string{"名詞"},
+	compoundPrefixes: []string{"接頭詞-名詞接続"},
+	compoundExcluded: []string{"名詞-非自立", "名詞-代名詞"},
+}
+
+var uniScheme = posScheme{
+	dict:             uni.Dict,
+	profiles:         uniProfiles,
+	compoundNouns:    []string{"名詞", "接尾辞-名詞的"},
+	compoundPrefixes: []string{"接頭辞"},
+	compoundExcluded: []string{"名詞-助動詞語幹"},
+}
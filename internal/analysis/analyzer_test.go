@@ -0,0 +1,174 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/takatori/skg/internal"
+)
+
+const benchmarkDocument = "機械学習は人工知能の一分野であり、データから学習したモデルを用いて予測や分類を行う。" +
+	"近年は深層学習の発展により、画像認識や自然言語処理の精度が大きく向上した。"
+
+func TestExtractTerms(t *testing.T) {
+	analyzer, err := NewAnalyzer(&internal.Config{})
+	if err != nil {
+		t.Fatalf("NewAnalyzer() error = %v", err)
+	}
+
+	terms := analyzer.ExtractTerms("東京で美味しいラーメンを食べた")
+	expected := []string{"東京", "ラーメン"}
+	if !reflect.DeepEqual(terms, expected) {
+		t.Errorf("ExtractTerms() = %v, expected %v", terms, expected)
+	}
+}
+
+// BenchmarkPerRequestTokenizer measures creating a tokenizer for every request, as handlers used to.
+// ipa.Dict memoizes the dictionary, so the dictionary itself is only loaded by the first iteration.
+func BenchmarkPerRequestTokenizer(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		analyzer, err := NewAnalyzer(&internal.Config{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = analyzer.ExtractTerms(benchmarkDocument)
+	}
+}
+
+// BenchmarkSharedAnalyzer measures extracting terms with an Analyzer shared across requests.
+func BenchmarkSharedAnalyzer(b *testing.B) {
+	analyzer, err := NewAnalyzer(&internal.Config{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = analyzer.ExtractTerms(benchmarkDocument)
+	}
+}
+
+// BenchmarkSharedAnalyzerParallel measures concurrent requests sharing a single Analyzer.
+func BenchmarkSharedAnalyzerParallel(b *testing.B) {
+	analyzer, err := NewAnalyzer(&internal.Config{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = analyzer.ExtractTerms(benchmarkDocument)
+		}
+	})
+}
+
+func TestAnalyzerUserDictionary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "userdict.csv")
+	if err := os.WriteFile(path, []byte("深層学習,深層学習,シンソウガクシュウ,カスタム名詞\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	analyzer, err := NewAnalyzer(&internal.Config{UserDictionaryPath: path})
+	if err != nil {
+		t.Fatalf("NewAnalyzer() error = %v", err)
+	}
+	document := "深層学習と機械学習"
+	expected := []string{"深層学習", "機械", "学習"}
+	if terms := analyzer.ExtractTerms(document); !reflect.DeepEqual(terms, expected) {
+		t.Errorf("ExtractTerms() = %v, expected %v", terms, expected)
+	}
+
+	// Words added to the user dictionary are picked up on reload
+	if err := os.WriteFile(path, []byte("深層学習,深層学習,シンソウガクシュウ,カスタム名詞\n機械学習,機械学習,キカイガクシュウ,カスタム名詞\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := analyzer.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	expected = []string{"深層学習", "機械学習"}
+	if terms := analyzer.ExtractTerms(document); !reflect.DeepEqual(terms, expected) {
+		t.Errorf("ExtractTerms() after Reload() = %v, expected %v", terms, expected)
+	}
+
+	// A broken user dictionary keeps the current tokenizer
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := analyzer.Reload(); err == nil {
+		t.Errorf("Reload() expected an error for a missing user dictionary")
+	}
+	if terms := analyzer.ExtractTerms(document); !reflect.DeepEqual(terms, expected) {
+		t.Errorf("ExtractTerms() after failed Reload() = %v, expected %v", terms, expected)
+	}
+}
+
+func TestAnalyzerMode(t *testing.T) {
+	document := "関西国際空港"
+	tests := []struct {
+		mode     string
+		expected []string
+	}{
+		{ModeNormal, []string{"関西国際空港"}},
+		{ModeSearch, []string{"関西", "国際", "空港"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			analyzer, err := NewAnalyzer(&internal.Config{TokenizerMode: test.mode})
+			if err != nil {
+				t.Fatalf("NewAnalyzer() error = %v", err)
+			}
+			if terms := analyzer.ExtractTerms(document); !reflect.DeepEqual(terms, test.expected) {
+				t.Errorf("ExtractTerms() = %v, expected %v", terms, test.expected)
+			}
+		})
+	}
+}
+
+func TestAnalyzerInvalidConfig(t *testing.T) {
+	configs := []*internal.Config{
+		{TokenizerDictionary: "neologd"},
+		{TokenizerMode: "fast"},
+		{UserDictionaryPath: filepath.Join(t.TempDir(), "missing.csv")},
+	}
+	for _, config := range configs {
+		if _, err := NewAnalyzer(config); err == nil {
+			t.Errorf("NewAnalyzer(%+v) expected an error", *config)
+		}
+	}
+}
+
+func TestAnalyzerUniDic(t *testing.T) {
+	analyzer, err := NewAnalyzer(&internal.Config{TokenizerDictionary: DictionaryUni})
+	if err != nil {
+		t.Fatalf("NewAnalyzer() error = %v", err)
+	}
+
+	tests := []struct {
+		profile  string
+		opts     ExtractOptions
+		expected []string
+	}{
+		{"nouns", ExtractOptions{}, []string{"機械", "学習", "モデル", "東京", "タワー"}},
+		{"content", ExtractOptions{}, []string{"機械", "学習", "モデル", "速い", "走る", "美しい", "東京", "タワー"}},
+		{"nouns", ExtractOptions{Units: UnitCompounds}, []string{"3個", "機械学習モデル", "東京タワー"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.profile, func(t *testing.T) {
+			extractor, err := analyzer.Extractor(test.profile, test.opts)
+			if err != nil {
+				t.Fatalf("Extractor() error = %v", err)
+			}
+			if terms := extractor.Extract(profileDocument); !reflect.DeepEqual(terms, test.expected) {
+				t.Errorf("Extract() = %v, expected %v", terms, test.expected)
+			}
+		})
+	}
+}
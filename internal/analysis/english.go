@@ -0,0 +1,110 @@
+package analysis
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// englishStopwords are frequent English words that carry little meaning on their own
+var englishStopwords = []string{
+	"a", "about", "after", "all", "also", "an", "and", "any", "are", "as", "at", "be", "been", "before",
+	"but", "by", "can", "could", "did", "do", "does", "for", "from", "had", "has", "have", "he", "her",
+	"his", "how", "i", "if", "in", "into", "is", "it", "its", "may", "more", "most", "my", "no", "not",
+	"of", "on", "or", "our", "over", "she", "should", "so", "some", "such", "than", "that", "the", "their",
+	"them", "then", "there", "these", "they", "this", "those", "through", "to", "under", "up", "us", "use",
+	"used", "using", "very", "was", "we", "were", "what", "when", "where", "which", "while", "who", "why",
+	"will", "with", "would", "you", "your",
+}
+
+// englishExtractor extracts lowercased and stemmed words from English text.
+// English has no parts of speech to filter on, so only the stopwords and minimum length of the profile apply,
+// in addition to englishStopwords; Units has no effect, and phrases are words joined with a space.
+type englishExtractor struct {
+	profile Profile
+	options ExtractOptions
+}
+
+// Extract implements Extractor
+func (e *englishExtractor) Extract(text string) []string {
+	// A word is kept as a term or nil, so that phrases never span a filtered word
+	words := splitWords(text)
+	kept := make([]*string, len(words))
+
+	var terms []string
+	for i, word := range words {
+		if term, ok := e.term(word); ok {
+			kept[i] = &term
+			terms = append(terms, term)
+		}
+	}
+
+	for n := 2; n <= e.options.NGram; n++ {
+	phrases:
+		for i := 0; i+n <= len(kept); i++ {
+			parts := make([]string, 0, n)
+			for _, term := range kept[i : i+n] {
+				if term == nil {
+					continue phrases
+				}
+				parts = append(parts, *term)
+			}
+			terms = append(terms, strings.Join(parts, " "))
+		}
+	}
+	return terms
+}
+
+// term returns the stem of the word if it is a letter word, long enough and not a stopword
+func (e *englishExtractor) term(word string) (string, bool) {
+	word = strings.ToLower(word)
+	if !strings.ContainsFunc(word, unicode.IsLetter) {
+		return "", false
+	}
+	if slices.Contains(englishStopwords, word) || slices.Contains(e.profile.Stopwords, word) {
+		return "", false
+	}
+	stem := stemEnglish(word)
+	if utf8.RuneCountInString(stem) < e.profile.MinLength {
+		return "", false
+	}
+	return stem, true
+}
+
+// splitWords splits the text into words of letters and digits, keeping apostrophes within words
+func splitWords(text string) []string {
+	isWordRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' || r == '’'
+	}
+	var words []string
+	for _, field := range strings.FieldsFunc(text, func(r rune) bool { return !isWordRune(r) }) {
+		if word := strings.Trim(field, "'’"); word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// stemEnglish strips possessives and plural endings from a lowercased word with a simple heuristic,
+// so that stems remain readable words rather than Porter stems. Words of up to 3 letters are kept;
+// otherwise "-ies" becomes "-y" unless after "a" or "e", and a final "s" is dropped except in "-us", "-ss", "-is",
+// "-aes", "-ees", "-ies" and "-oes". "-es" is not handled, so "boxes" becomes "boxe".
+func stemEnglish(word string) string {
+	for _, possessive := range []string{"'s", "’s"} {
+		word = strings.TrimSuffix(word, possessive)
+	}
+	if utf8.RuneCountInString(word) <= 3 || !strings.HasSuffix(word, "s") {
+		return word
+	}
+	switch {
+	case strings.HasSuffix(word, "us") || strings.HasSuffix(word, "ss") || strings.HasSuffix(word, "is"):
+		return word
+	case strings.HasSuffix(word, "ies") && !strings.HasSuffix(word, "aies") && !strings.HasSuffix(word, "eies"):
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "aes") || strings.HasSuffix(word, "ees") ||
+		strings.HasSuffix(word, "ies") || strings.HasSuffix(word, "oes"):
+		return word
+	}
+	return word[:len(word)-1]
+}
@@ -0,0 +1,321 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ikawaha/kagome/v2/tokenizer"
+)
+
+// DefaultProfile is the name of the profile used when none is chosen
+const DefaultProfile = "nouns"
+
+// maxNGram is the longest phrase candidate that can be requested
+const maxNGram = 5
+
+// Extractor extracts candidate terms from text
+type Extractor interface {
+	Extract(text string) []string
+}
+
+// Units selects whether single tokens, compound nouns or both are extracted
+type Units string
+
+const (
+	// UnitTokens extracts every token the profile keeps, one morpheme at a time
+	UnitTokens Units = "tokens"
+	// UnitCompounds joins consecutive nouns and prefixes into compound nouns,
+	// e.g. "機械学習" instead of "機械" and "学習"; other tokens are extracted as with UnitTokens
+	UnitCompounds Units = "compounds"
+	// UnitBoth extracts the tokens of compound nouns as well as the compound nouns
+	UnitBoth Units = "both"
+)
+
+// ExtractOptions controls which candidates an Extractor produces besides the profile's filters
+type ExtractOptions struct {
+	Units Units // defaults to UnitTokens
+	// NGram adds phrase candidates of 2 to NGram adjacent content tokens joined together,
+	// e.g. "人工知能研究" for NGram 3; 0 or 1 disables phrases
+	NGram int
+	// Language is LanguageJapanese, LanguageEnglish or LanguageAuto (default) to detect it for each text
+	Language string
+}
+
+// validate validates the options
+func (o ExtractOptions) validate() error {
+	switch o.Units {
+	case "", UnitTokens, UnitCompounds, UnitBoth:
+	default:
+		return fmt.Errorf("invalid units: %q", o.Units)
+	}
+	if o.NGram < 0 || o.NGram > maxNGram {
+		return fmt.Errorf("ngram must be between 0 and %d: %d", maxNGram, o.NGram)
+	}
+	return validateLanguage(o.Language)
+}
+
+// Profile configures which tokens an Extractor keeps as terms.
+//
+// Parts of speech are written as the POS hierarchy joined with "-", e.g. "名詞" or "名詞-数",
+// and match every token whose POS starts with them. A token is kept if it matches any of IncludePOS
+// (or IncludePOS is empty) and none of ExcludePOS, is not a stopword and has at least MinLength characters.
+type Profile struct {
+	Name       string   `json:"name"`
+	IncludePOS []string `json:"includePos"`
+	ExcludePOS []string `json:"excludePos"`
+	Stopwords  []string `json:"stopwords"`
+	MinLength  int      `json:"minLength"`
+}
+
+// defaultStopwords are frequent Japanese words that carry little meaning on their own
+var defaultStopwords = []string{
+	"こと", "もの", "ため", "よう", "ところ", "とき", "ほう", "これ", "それ", "あれ", "どれ",
+	"ここ", "そこ", "あそこ", "どこ", "方", "等", "中", "上", "下", "前", "後", "他",
+}
+
+// BuiltinProfiles returns the profiles that are always available with the IPA dictionary:
+//
+//   - "nouns" keeps nouns, except numerals, pronouns, suffixes and dependent nouns
+//   - "content" keeps the nouns of "nouns" plus independent verbs and adjectives
+//   - "all_nouns" keeps every noun, like term extraction did before profiles were introduced
+//
+// The UniDic dictionary has profiles with the same names and behavior written in its parts of speech.
+func BuiltinProfiles() []Profile {
+	return ipaProfiles()
+}
+
+// ipaProfiles returns the built-in profiles for the IPA dictionary
+func ipaProfiles() []Profile {
+	nounExclusions := []string{"名詞-数", "名詞-代名詞", "名詞-接尾", "名詞-非自立"}
+	return []Profile{
+		{
+			Name:       "nouns",
+			IncludePOS: []string{"名詞"},
+			ExcludePOS: nounExclusions,
+			Stopwords:  defaultStopwords,
+			MinLength:  1,
+		},
+		{
+			Name:       "content",
+			IncludePOS: []string{"名詞", "動詞-自立", "形容詞-自立"},
+			ExcludePOS: nounExclusions,
+			Stopwords:  slices.Concat(defaultStopwords, []string{"する", "ある", "いる", "なる", "できる"}),
+			MinLength:  1,
+		},
+		{
+			Name:       "all_nouns",
+			IncludePOS: []string{"名詞"},
+		},
+	}
+}
+
+// uniProfiles returns the built-in profiles for the UniDic dictionary,
+// where pronouns and suffixes are parts of speech of their own rather than nouns
+func uniProfiles() []Profile {
+	nounExclusions := []string{"名詞-数詞", "名詞-助動詞語幹"}
+	return []Profile{
+		{
+			Name:       "nouns",
+			IncludePOS: []string{"名詞"},
+			ExcludePOS: nounExclusions,
+			Stopwords:  defaultStopwords,
+			MinLength:  1,
+		},
+		{
+			Name:       "content",
+			IncludePOS: []string{"名詞", "動詞", "形容詞"},
+			ExcludePOS: slices.Concat(nounExclusions, []string{"動詞-非自立可能", "形容詞-非自立可能"}),
+			Stopwords:  slices.Concat(defaultStopwords, []string{"する", "ある", "いる", "なる", "できる"}),
+			MinLength:  1,
+		},
+		{
+			Name:       "all_nouns",
+			IncludePOS: []string{"名詞", "代名詞", "接尾辞-名詞的"},
+		},
+	}
+}
+
+// LoadProfiles reads profiles from a JSON file holding an array of profiles
+func LoadProfiles(path string) ([]Profile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read extraction profiles: %w", err)
+	}
+	var profiles []Profile
+	if err := json.Unmarshal(b, &profiles); err != nil {
+		return nil, fmt.Errorf("failed to parse extraction profiles: %w", err)
+	}
+	for i, p := range profiles {
+		if p.Name == "" {
+			return nil, fmt.Errorf("extraction profile %d has no name", i)
+		}
+	}
+	return profiles, nil
+}
+
+// tokenExtractor extracts the base form of the tokens the profile keeps,
+// and compound nouns and phrases depending on the options.
+// Words of the user dictionary are kept by every profile, subject to its stopwords and minimum length.
+type tokenExtractor struct {
+	state   *tokenizerState
+	profile Profile
+	options ExtractOptions
+}
+
+// Extract implements Extractor
+func (e *tokenExtractor) Extract(text string) []string {
+	tokens := e.state.tokenizer.Analyze(text, e.state.mode)
+
+	var terms []string
+	for i := 0; i < len(tokens); {
+		if e.options.Units == UnitCompounds || e.options.Units == UnitBoth {
+			if end := e.compoundEnd(tokens, i); end-i >= 2 {
+				if e.options.Units == UnitBoth {
+					for _, token := range tokens[i:end] {
+						if term, ok := e.term(token); ok {
+							terms = append(terms, term)
+						}
+					}
+				}
+				if compound, ok := e.filter(joinSurfaces(tokens[i:end])); ok {
+					terms = append(terms, compound)
+				}
+				i = end
+				continue
+			}
+		}
+
+		if term, ok := e.term(tokens[i]); ok {
+			terms = append(terms, term)
+		}
+		i++
+	}
+
+	for n := 2; n <= e.options.NGram; n++ {
+		for i := 0; i+n <= len(tokens); i++ {
+			if !e.isPhrase(tokens[i : i+n]) {
+				continue
+			}
+			if phrase, ok := e.filter(joinSurfaces(tokens[i : i+n])); ok {
+				terms = append(terms, phrase)
+			}
+		}
+	}
+	return terms
+}
+
+// isPhrase reports whether the tokens make up a phrase candidate:
+// every token is kept by the profile or is part of a compound noun
+func (e *tokenExtractor) isPhrase(tokens []tokenizer.Token) bool {
+	for _, token := range tokens {
+		if _, ok := e.term(token); !ok && !e.isCompoundPart(token) {
+			return false
+		}
+	}
+	return true
+}
+
+// compoundEnd returns the end of the compound noun starting at the i-th token,
+// which is i if the token cannot start a compound noun.
+// A compound noun is a run of nouns and prefixes that does not end with a prefix.
+func (e *tokenExtractor) compoundEnd(tokens []tokenizer.Token, i int) int {
+	end := i
+	for j := i; j < len(tokens) && e.isCompoundPart(tokens[j]); j++ {
+		if !matchAnyPOS(tokens[j].POS(), e.state.scheme.compoundPrefixes) {
+			end = j + 1
+		}
+	}
+	return end
+}
+
+// isCompoundPart reports whether the token can be part of a compound noun
+func (e *tokenExtractor) isCompoundPart(token tokenizer.Token) bool {
+	switch token.Class {
+	case tokenizer.DUMMY:
+		return false
+	case tokenizer.USER:
+		return true
+	}
+	pos := token.POS()
+	if matchAnyPOS(pos, e.state.scheme.compoundPrefixes) {
+		return true
+	}
+	return matchAnyPOS(pos, e.state.scheme.compoundNouns) && !matchAnyPOS(pos, e.state.scheme.compoundExcluded)
+}
+
+// joinSurfaces joins the surface forms of the tokens
+func joinSurfaces(tokens []tokenizer.Token) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteString(token.Surface)
+	}
+	return b.String()
+}
+
+// term returns the term for the token if the profile keeps it
+func (e *tokenExtractor) term(token tokenizer.Token) (string, bool) {
+	switch token.Class {
+	case tokenizer.DUMMY:
+		// Skip BOS/EOS and other non-content tokens
+		return "", false
+	case tokenizer.USER:
+		return e.filter(token.Surface)
+	}
+
+	pos := token.POS()
+	if len(e.profile.IncludePOS) > 0 && !matchAnyPOS(pos, e.profile.IncludePOS) {
+		return "", false
+	}
+	if matchAnyPOS(pos, e.profile.ExcludePOS) {
+		return "", false
+	}
+
+	return e.filter(baseForm(token))
+}
+
+// filter returns the term if it is long enough and not a stopword
+func (e *tokenExtractor) filter(term string) (string, bool) {
+	if utf8.RuneCountInString(term) < e.profile.MinLength {
+		return "", false
+	}
+	if slices.Contains(e.profile.Stopwords, term) {
+		return "", false
+	}
+	return term, true
+}
+
+// baseForm returns the base form of the token if available, or its surface form otherwise
+func baseForm(token tokenizer.Token) string {
+	if base, ok := token.BaseForm(); ok && base != "" && base != "*" {
+		return base
+	}
+	return token.Surface
+}
+
+// matchAnyPOS reports whether the POS hierarchy matches any of the patterns
+func matchAnyPOS(pos []string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matchPOS(pos, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPOS reports whether the POS hierarchy starts with the "-" separated pattern
+func matchPOS(pos []string, pattern string) bool {
+	parts := strings.Split(pattern, "-")
+	if len(parts) > len(pos) {
+		return false
+	}
+	for i, part := range parts {
+		if pos[i] != part {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,188 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/errors"
+)
+
+const profileDocument = "私は3個の機械学習モデルを速く走らせた。美しい東京タワーのことです"
+
+func TestExtractorProfiles(t *testing.T) {
+	custom := Profile{
+		Name:       "proper_nouns",
+		IncludePOS: []string{"名詞-固有名詞"},
+	}
+	analyzer, err := NewAnalyzer(&internal.Config{}, custom)
+	if err != nil {
+		t.Fatalf("NewAnalyzer() error = %v", err)
+	}
+
+	tests := []struct {
+		profile  string
+		expected []string
+	}{
+		{"", []string{"機械", "学習", "モデル", "東京タワー"}},
+		{"nouns", []string{"機械", "学習", "モデル", "東京タワー"}},
+		{"content", []string{"機械", "学習", "モデル", "速い", "走る", "美しい", "東京タワー"}},
+		{"all_nouns", []string{"私", "3", "個", "機械", "学習", "モデル", "東京タワー", "こと"}},
+		{"proper_nouns", []string{"東京タワー"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.profile, func(t *testing.T) {
+			extractor, err := analyzer.Extractor(test.profile, ExtractOptions{})
+			if err != nil {
+				t.Fatalf("Extractor() error = %v", err)
+			}
+			terms := extractor.Extract(profileDocument)
+			if !reflect.DeepEqual(terms, test.expected) {
+				t.Errorf("Extract() = %v, expected %v", terms, test.expected)
+			}
+		})
+	}
+}
+
+func TestExtractorStopwordsAndMinLength(t *testing.T) {
+	analyzer, err := NewAnalyzer(&internal.Config{}, Profile{
+		Name:       "strict",
+		IncludePOS: []string{"名詞"},
+		ExcludePOS: []string{"名詞-数"},
+		Stopwords:  []string{"モデル"},
+		MinLength:  2,
+	})
+	if err != nil {
+		t.Fatalf("NewAnalyzer() error = %v", err)
+	}
+
+	extractor, err := analyzer.Extractor("strict", ExtractOptions{})
+	if err != nil {
+		t.Fatalf("Extractor() error = %v", err)
+	}
+	terms := extractor.Extract(profileDocument)
+	expected := []string{"機械", "学習", "東京タワー", "こと"}
+	if !reflect.DeepEqual(terms, expected) {
+		t.Errorf("Extract() = %v, expected %v", terms, expected)
+	}
+}
+
+func TestExtractorUnknownProfile(t *testing.T) {
+	analyzer, err := NewAnalyzer(&internal.Config{})
+	if err != nil {
+		t.Fatalf("NewAnalyzer() error = %v", err)
+	}
+	if _, err := analyzer.Extractor("missing", ExtractOptions{}); !failure.Is(err, errors.ErrBadRequest) {
+		t.Errorf("Extractor() error = %v, expected %s", err, errors.ErrBadRequest)
+	}
+	if _, err := analyzer.Extractor("", ExtractOptions{Units: "sentences"}); !failure.Is(err, errors.ErrBadRequest) {
+		t.Errorf("Extractor() error = %v, expected %s", err, errors.ErrBadRequest)
+	}
+	if _, err := analyzer.Extractor("", ExtractOptions{NGram: maxNGram + 1}); !failure.Is(err, errors.ErrBadRequest) {
+		t.Errorf("Extractor() error = %v, expected %s", err, errors.ErrBadRequest)
+	}
+}
+
+func TestExtractorUnits(t *testing.T) {
+	analyzer, err := NewAnalyzer(&internal.Config{})
+	if err != nil {
+		t.Fatalf("NewAnalyzer() error = %v", err)
+	}
+	document := "新製品の機械学習モデルは人工知能研究所で開発された"
+
+	tests := []struct {
+		name     string
+		opts     ExtractOptions
+		expected []string
+	}{
+		{
+			name:     "tokens",
+			opts:     ExtractOptions{},
+			expected: []string{"製品", "機械", "学習", "モデル", "人工", "知能", "研究所", "開発"},
+		},
+		{
+			name:     "compounds",
+			opts:     ExtractOptions{Units: UnitCompounds},
+			expected: []string{"新製品", "機械学習モデル", "人工知能研究所", "開発"},
+		},
+		{
+			name: "both",
+			opts: ExtractOptions{Units: UnitBoth},
+			expected: []string{
+				"製品", "新製品",
+				"機械", "学習", "モデル", "機械学習モデル",
+				"人工", "知能", "研究所", "人工知能研究所",
+				"開発",
+			},
+		},
+		{
+			name: "ngrams",
+			opts: ExtractOptions{NGram: 2},
+			expected: []string{
+				"製品", "機械", "学習", "モデル", "人工", "知能", "研究所", "開発",
+				"新製品", "機械学習", "学習モデル", "人工知能", "知能研究所",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			extractor, err := analyzer.Extractor("", test.opts)
+			if err != nil {
+				t.Fatalf("Extractor() error = %v", err)
+			}
+			terms := extractor.Extract(document)
+			if !reflect.DeepEqual(terms, test.expected) {
+				t.Errorf("Extract() = %v, expected %v", terms, test.expected)
+			}
+		})
+	}
+}
+
+func TestMatchPOS(t *testing.T) {
+	tests := []struct {
+		pos      []string
+		pattern  string
+		expected bool
+	}{
+		{[]string{"名詞", "数", "*", "*"}, "名詞", true},
+		{[]string{"名詞", "数", "*", "*"}, "名詞-数", true},
+		{[]string{"名詞", "一般", "*", "*"}, "名詞-数", false},
+		{[]string{"動詞", "自立", "*", "*"}, "名詞", false},
+		{[]string{"名詞"}, "名詞-数", false},
+	}
+
+	for _, test := range tests {
+		if result := matchPOS(test.pos, test.pattern); result != test.expected {
+			t.Errorf("matchPOS(%v, %q) = %v, expected %v", test.pos, test.pattern, result, test.expected)
+		}
+	}
+}
+
+func TestLoadProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	content := `[{"name":"nums","includePos":["名詞-数"],"minLength":1}]`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	profiles, err := LoadProfiles(path)
+	if err != nil {
+		t.Fatalf("LoadProfiles() error = %v", err)
+	}
+	expected := []Profile{{Name: "nums", IncludePOS: []string{"名詞-数"}, MinLength: 1}}
+	if !reflect.DeepEqual(profiles, expected) {
+		t.Errorf("LoadProfiles() = %+v, expected %+v", profiles, expected)
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"includePos":["名詞"]}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadProfiles(path); err == nil {
+		t.Errorf("LoadProfiles() expected an error for a profile without a name")
+	}
+}
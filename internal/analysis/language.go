@@ -0,0 +1,63 @@
+package analysis
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// Languages supported by term extraction
+const (
+	// LanguageAuto detects the language of each text with DetectLanguage
+	LanguageAuto     = "auto"
+	LanguageJapanese = "ja"
+	LanguageEnglish  = "en"
+)
+
+// validateLanguage validates a language of ExtractOptions
+func validateLanguage(language string) error {
+	switch language {
+	case "", LanguageAuto, LanguageJapanese, LanguageEnglish:
+		return nil
+	}
+	return fmt.Errorf("unsupported language: %q", language)
+}
+
+// ResolveLanguage returns the language, or the detected language of the text
+// if the language is empty or LanguageAuto
+func ResolveLanguage(language, text string) string {
+	if language == "" || language == LanguageAuto {
+		return DetectLanguage(text)
+	}
+	return language
+}
+
+// DetectLanguage detects whether the text is Japanese or English from the scripts of its letters.
+//
+// Japanese text often contains English words, and a Japanese word takes fewer letters than an English one,
+// so the text is Japanese unless Latin letters outnumber kana and kanji more than three to one.
+// Text without letters is considered Japanese.
+func DetectLanguage(text string) string {
+	var japanese, latin int
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Han):
+			japanese++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+	if latin > 3*japanese {
+		return LanguageEnglish
+	}
+	return LanguageJapanese
+}
+
+// languageExtractor detects the language of each text and extracts its terms with the matching Extractor
+type languageExtractor struct {
+	extractors map[string]Extractor
+}
+
+// Extract implements Extractor
+func (e *languageExtractor) Extract(text string) []string {
+	return e.extractors[DetectLanguage(text)].Extract(text)
+}
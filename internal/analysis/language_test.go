@@ -0,0 +1,114 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/errors"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"機械学習のモデル", LanguageJapanese},
+		{"TensorFlowで機械学習モデルを学習する", LanguageJapanese},
+		{"Machine learning models are trained on data", LanguageEnglish},
+		{"Deep learning (深層学習) improves image recognition", LanguageEnglish},
+		{"12345", LanguageJapanese},
+		{"", LanguageJapanese},
+	}
+
+	for _, test := range tests {
+		if result := DetectLanguage(test.text); result != test.expected {
+			t.Errorf("DetectLanguage(%q) = %q, expected %q", test.text, result, test.expected)
+		}
+	}
+}
+
+func TestStemEnglish(t *testing.T) {
+	tests := []struct {
+		word     string
+		expected string
+	}{
+		{"models", "model"},
+		{"studies", "study"},
+		{"boxes", "boxe"},
+		{"heroes", "heroes"},
+		{"shoes", "shoes"},
+		{"class", "class"},
+		{"status", "status"},
+		{"analysis", "analysis"},
+		{"google's", "google"},
+		{"gas", "gas"},
+		{"learning", "learning"},
+	}
+
+	for _, test := range tests {
+		if result := stemEnglish(test.word); result != test.expected {
+			t.Errorf("stemEnglish(%q) = %q, expected %q", test.word, result, test.expected)
+		}
+	}
+}
+
+func TestExtractorLanguages(t *testing.T) {
+	analyzer, err := NewAnalyzer(&internal.Config{})
+	if err != nil {
+		t.Fatalf("NewAnalyzer() error = %v", err)
+	}
+	english := "The Models of Google's machine-learning studies."
+
+	tests := []struct {
+		name     string
+		text     string
+		opts     ExtractOptions
+		expected []string
+	}{
+		{
+			name:     "english",
+			text:     english,
+			opts:     ExtractOptions{Language: LanguageEnglish},
+			expected: []string{"model", "google", "machine", "learning", "study"},
+		},
+		{
+			name: "english phrases",
+			text: english,
+			opts: ExtractOptions{Language: LanguageEnglish, NGram: 2},
+			expected: []string{
+				"model", "google", "machine", "learning", "study",
+				"google machine", "machine learning", "learning study",
+			},
+		},
+		{
+			name:     "auto english",
+			text:     english,
+			opts:     ExtractOptions{},
+			expected: []string{"model", "google", "machine", "learning", "study"},
+		},
+		{
+			name:     "auto japanese",
+			text:     "機械学習のモデル",
+			opts:     ExtractOptions{Language: LanguageAuto},
+			expected: []string{"機械", "学習", "モデル"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			extractor, err := analyzer.Extractor("", test.opts)
+			if err != nil {
+				t.Fatalf("Extractor() error = %v", err)
+			}
+			if terms := extractor.Extract(test.text); !reflect.DeepEqual(terms, test.expected) {
+				t.Errorf("Extract() = %v, expected %v", terms, test.expected)
+			}
+		})
+	}
+
+	if _, err := analyzer.Extractor("", ExtractOptions{Language: "fr"}); !failure.Is(err, errors.ErrBadRequest) {
+		t.Errorf("Extractor() error = %v, expected %s", err, errors.ErrBadRequest)
+	}
+}
@@ -1,6 +1,8 @@
 package internal
 
 import (
+	"time"
+
 	"github.com/kelseyhightower/envconfig"
 )
 
@@ -8,6 +10,76 @@ type Config struct {
 	Env      RunEnv `envconfig:"ENV" default:"development"`
 	EchoAddr string `envconfig:"ECHO_ADDR" default:":8080"`
 	SolrUrl  string `envconfig:"SOLR_URL" default:"http://solr:8983/solr"`
+
+	// SolrUsername and SolrPassword enable basic auth for requests to Solr.
+	SolrUsername string `envconfig:"SOLR_USERNAME"`
+	SolrPassword string `envconfig:"SOLR_PASSWORD"`
+	// SolrBearerTokenFile is a file holding a bearer token (e.g. a JWT) for requests to Solr.
+	// The file is read again when it changes, so the token can be rotated without a restart.
+	SolrBearerTokenFile string `envconfig:"SOLR_BEARER_TOKEN_FILE"`
+	// SolrTLSCertFile and SolrTLSKeyFile are the PEM client certificate and key for mutual TLS with Solr.
+	SolrTLSCertFile string `envconfig:"SOLR_TLS_CERT_FILE"`
+	SolrTLSKeyFile  string `envconfig:"SOLR_TLS_KEY_FILE"`
+	// SolrTLSCAFile is a PEM file of the CA certificates trusted for Solr's server certificate,
+	// instead of the system's.
+	SolrTLSCAFile string `envconfig:"SOLR_TLS_CA_FILE"`
+
+	// HttpTimeout is how long a single attempt of a request to Solr may take, unless overridden by the call.
+	HttpTimeout time.Duration `envconfig:"HTTP_TIMEOUT" default:"10s"`
+	// AdminTimeout is how long a request to Solr's Collections API may take, e.g. to create a collection.
+	AdminTimeout time.Duration `envconfig:"ADMIN_TIMEOUT" default:"3m"`
+	// FeedTimeout is how long an upload of a batch of documents to Solr's update API may take.
+	FeedTimeout time.Duration `envconfig:"FEED_TIMEOUT" default:"5m"`
+	// FeedBatchSize is the default number of documents sent to Solr per update request.
+	FeedBatchSize int `envconfig:"FEED_BATCH_SIZE" default:"1000"`
+	// FeedConcurrency is the default number of update requests sent to Solr at the same time.
+	FeedConcurrency int `envconfig:"FEED_CONCURRENCY" default:"2"`
+	// FeedMaxBatchSize is the largest batch size a feed request may ask for.
+	FeedMaxBatchSize int `envconfig:"FEED_MAX_BATCH_SIZE" default:"10000"`
+	// FeedMaxConcurrency is the largest concurrency a feed request may ask for.
+	FeedMaxConcurrency int `envconfig:"FEED_MAX_CONCURRENCY" default:"8"`
+	// FeedJobWorkers is how many asynchronous feed jobs run at the same time.
+	FeedJobWorkers int `envconfig:"FEED_JOB_WORKERS" default:"2"`
+	// FeedJobQueueSize is how many asynchronous feed jobs may wait for a worker; more are rejected.
+	FeedJobQueueSize int `envconfig:"FEED_JOB_QUEUE_SIZE" default:"16"`
+	// FeedJobRetention is how long finished feed jobs are kept in memory.
+	FeedJobRetention time.Duration `envconfig:"FEED_JOB_RETENTION" default:"24h"`
+	// IngestPipelinesPath is an optional JSON file persisting the named ingest pipelines applied to fed documents.
+	IngestPipelinesPath string `envconfig:"INGEST_PIPELINES_PATH"`
+	// CollectionPipelines maps collections to the ingest pipeline applied to documents fed without one,
+	// e.g. "products:products_v1".
+	CollectionPipelines map[string]string `envconfig:"COLLECTION_PIPELINES"`
+	// HttpMaxRetries is how many times an idempotent request to Solr is retried on transient failures.
+	HttpMaxRetries int `envconfig:"HTTP_MAX_RETRIES" default:"2"`
+	// HttpRetryBaseDelay bounds the jittered delay before the first retry; the bound doubles for each retry.
+	HttpRetryBaseDelay time.Duration `envconfig:"HTTP_RETRY_BASE_DELAY" default:"100ms"`
+	// HttpRetryMaxDelay bounds the delay before any retry.
+	HttpRetryMaxDelay time.Duration `envconfig:"HTTP_RETRY_MAX_DELAY" default:"2s"`
+	// CircuitBreakerThreshold is how many consecutive transient failures open the circuit of a host; 0 disables it.
+	CircuitBreakerThreshold int `envconfig:"CIRCUIT_BREAKER_THRESHOLD" default:"5"`
+	// CircuitBreakerCooldown is how long an open circuit rejects requests before letting a trial request through.
+	CircuitBreakerCooldown time.Duration `envconfig:"CIRCUIT_BREAKER_COOLDOWN" default:"30s"`
+
+	// DefaultTextField is the field holding the text of documents in collections without a mapping.
+	DefaultTextField string `envconfig:"DEFAULT_TEXT_FIELD" default:"text"`
+	// CollectionTextFields maps collections to their text field, e.g. "products:title_ja,articles:body".
+	CollectionTextFields map[string]string `envconfig:"COLLECTION_TEXT_FIELDS"`
+	// LanguageTextFields maps languages of documents to the text field analyzed for them, e.g. "en:text_en,ja:text_ja".
+	// Keys may be "<collection>/<language>" to override the field of a single collection.
+	LanguageTextFields map[string]string `envconfig:"LANGUAGE_TEXT_FIELDS"`
+	// SchemaCacheTTL is how long a collection's schema is cached for field validation.
+	SchemaCacheTTL time.Duration `envconfig:"SCHEMA_CACHE_TTL" default:"1m"`
+	// ExtractionProfilesPath is an optional JSON file of term extraction profiles added to the built-in ones.
+	ExtractionProfilesPath string `envconfig:"EXTRACTION_PROFILES_PATH"`
+	// ExtractionAnalysis is how terms are extracted from documents by default: "local" with the Kagome analyzer,
+	// or "solr" with the index analyzer of the target field through Solr's field analysis API.
+	ExtractionAnalysis string `envconfig:"EXTRACTION_ANALYSIS" default:"local"`
+	// TokenizerDictionary is the system dictionary of the tokenizer, "ipa" or "uni" (UniDic).
+	TokenizerDictionary string `envconfig:"TOKENIZER_DICTIONARY" default:"ipa"`
+	// TokenizerMode is the tokenizer mode, "normal", "search" or "extended".
+	TokenizerMode string `envconfig:"TOKENIZER_MODE" default:"normal"`
+	// UserDictionaryPath is an optional Kagome user dictionary CSV file.
+	UserDictionaryPath string `envconfig:"USER_DICTIONARY_PATH"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -18,3 +90,22 @@ func LoadConfig() (*Config, error) {
 	}
 	return &cfg, nil
 }
+
+// TextField returns the field holding the text of documents in the collection.
+func (c *Config) TextField(collection string) string {
+	if field, ok := c.CollectionTextFields[collection]; ok && field != "" {
+		return field
+	}
+	return c.DefaultTextField
+}
+
+// LanguageTextField returns the field holding text of the language in the collection,
+// falling back to the collection's text field if the language has no mapping.
+func (c *Config) LanguageTextField(collection, language string) string {
+	for _, key := range []string{collection + "/" + language, language} {
+		if field, ok := c.LanguageTextFields[key]; ok && field != "" {
+			return field
+		}
+	}
+	return c.TextField(collection)
+}
@@ -1,8 +1,31 @@
 package errors
 
+import "net/http"
+
 type ErrorCode string
 
 const (
-	ErrNotFound ErrorCode = "NotFound"
-	ErrInternal ErrorCode = "Internal"
+	ErrNotFound    ErrorCode = "NotFound"
+	ErrInternal    ErrorCode = "Internal"
+	ErrBadRequest  ErrorCode = "BadRequest"
+	ErrTimeout     ErrorCode = "Timeout"
+	ErrConflict    ErrorCode = "Conflict"
+	ErrUnavailable ErrorCode = "Unavailable"
 )
+
+// CodeOfStatus returns the error code of an HTTP error status code
+func CodeOfStatus(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrBadRequest
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return ErrTimeout
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return ErrUnavailable
+	}
+	return ErrInternal
+}
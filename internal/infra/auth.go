@@ -0,0 +1,130 @@
+package infra
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/takatori/skg/internal"
+)
+
+// Authenticator adds credentials to the requests sent to Solr
+type Authenticator interface {
+	Authenticate(r *http.Request) error
+}
+
+// NewAuthenticator returns the Authenticator configured in the config:
+// basic auth if a username is set, a bearer token if a token file is set, or nil for none
+func NewAuthenticator(config *internal.Config) (Authenticator, error) {
+	switch {
+	case config.SolrUsername != "" && config.SolrBearerTokenFile != "":
+		return nil, fmt.Errorf("basic auth and bearer token auth cannot be used together")
+	case config.SolrUsername != "":
+		return &BasicAuth{Username: config.SolrUsername, Password: config.SolrPassword}, nil
+	case config.SolrBearerTokenFile != "":
+		return NewBearerTokenFile(config.SolrBearerTokenFile)
+	}
+	return nil, nil
+}
+
+// BasicAuth authenticates requests with HTTP basic auth
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Authenticate implements Authenticator
+func (a *BasicAuth) Authenticate(r *http.Request) error {
+	r.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+// BearerTokenFile authenticates requests with a bearer token read from a file, such as a JWT
+// mounted from a secret. The file is read again whenever it changes, so rotated tokens are used
+// without a restart.
+type BearerTokenFile struct {
+	path string
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	size    int64
+}
+
+// NewBearerTokenFile creates a new BearerTokenFile, reading the token from the file
+func NewBearerTokenFile(path string) (*BearerTokenFile, error) {
+	a := &BearerTokenFile{path: path}
+	if _, err := a.current(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Authenticate implements Authenticator
+func (a *BearerTokenFile) Authenticate(r *http.Request) error {
+	token, err := a.current()
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// current returns the token, reading the file again if it has changed since it was last read
+func (a *BearerTokenFile) current() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read bearer token: %w", err)
+	}
+	if a.token != "" && info.ModTime().Equal(a.modTime) && info.Size() == a.size {
+		return a.token, nil
+	}
+
+	content, err := os.ReadFile(a.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read bearer token: %w", err)
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("bearer token file %s is empty", a.path)
+	}
+	a.token, a.modTime, a.size = token, info.ModTime(), info.Size()
+	return a.token, nil
+}
+
+// newTLSConfig returns the TLS configuration of the connections to Solr with the client certificate
+// and CA certificates of the config, or nil to use the defaults
+func newTLSConfig(config *internal.Config) (*tls.Config, error) {
+	if config.SolrTLSCertFile == "" && config.SolrTLSKeyFile == "" && config.SolrTLSCAFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.SolrTLSCertFile != "" || config.SolrTLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.SolrTLSCertFile, config.SolrTLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if config.SolrTLSCAFile != "" {
+		pem, err := os.ReadFile(config.SolrTLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificates: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no CA certificates found in %s", config.SolrTLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,168 @@
+package infra
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/takatori/skg/internal"
+)
+
+// authServer returns a server answering {"ok":true} that reports the Authorization header of each request
+func authServer(t *testing.T) (*httptest.Server, chan string) {
+	t.Helper()
+	headers := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, headers
+}
+
+func TestHttpClientBasicAuth(t *testing.T) {
+	server, headers := authServer(t)
+	client := newTestClient(t, &internal.Config{SolrUsername: "solr", SolrPassword: "SolrRocks"})
+
+	var resp map[string]bool
+	if err := client.Get(context.Background(), Request{Url: server.URL}, &resp); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if header, expected := <-headers, "Basic c29scjpTb2xyUm9ja3M="; header != expected {
+		t.Errorf("Authorization = %q, expected %q", header, expected)
+	}
+}
+
+func TestHttpClientBearerTokenRotation(t *testing.T) {
+	server, headers := authServer(t)
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client := newTestClient(t, &internal.Config{SolrBearerTokenFile: path})
+
+	var resp map[string]bool
+	if err := client.Get(context.Background(), Request{Url: server.URL}, &resp); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if header := <-headers; header != "Bearer first" {
+		t.Errorf("Authorization = %q, expected %q", header, "Bearer first")
+	}
+
+	// Rotate the token, making sure its modification time changes
+	if err := os.WriteFile(path, []byte("second\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Get(context.Background(), Request{Url: server.URL}, &resp); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if header := <-headers; header != "Bearer second" {
+		t.Errorf("Authorization = %q after rotation, expected %q", header, "Bearer second")
+	}
+}
+
+func TestNewHttpClientInvalidAuth(t *testing.T) {
+	dir := t.TempDir()
+	configs := []*internal.Config{
+		{SolrUsername: "solr", SolrBearerTokenFile: filepath.Join(dir, "token")},
+		{SolrBearerTokenFile: filepath.Join(dir, "missing")},
+		{SolrTLSCertFile: filepath.Join(dir, "missing.pem"), SolrTLSKeyFile: filepath.Join(dir, "missing.key")},
+		{SolrTLSCAFile: filepath.Join(dir, "missing.pem")},
+	}
+	for _, config := range configs {
+		if _, err := NewHttpClient(config); err == nil {
+			t.Errorf("NewHttpClient(%+v) expected an error", *config)
+		}
+	}
+}
+
+func TestHttpClientMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	clientCert := writeCertificate(t, dir, "client")
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert.Leaf)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp map[string]bool
+	client := newTestClient(t, &internal.Config{
+		SolrTLSCertFile: filepath.Join(dir, "client.pem"),
+		SolrTLSKeyFile:  filepath.Join(dir, "client.key"),
+		SolrTLSCAFile:   caFile,
+	})
+	if err := client.Get(context.Background(), Request{Url: server.URL}, &resp); err != nil {
+		t.Errorf("Get() with a client certificate error = %v", err)
+	}
+
+	client = newTestClient(t, &internal.Config{SolrTLSCAFile: caFile})
+	if err := client.Get(context.Background(), Request{Url: server.URL}, &resp); err == nil {
+		t.Errorf("Get() without a client certificate expected an error")
+	}
+}
+
+// writeCertificate writes a self-signed client certificate and its key as <name>.pem and <name>.key in dir
+func writeCertificate(t *testing.T, dir, name string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(filepath.Join(dir, name+".pem"), certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
@@ -0,0 +1,102 @@
+package infra
+
+import (
+	"net/url"
+	"sync"
+	"time"
+)
+
+// circuitBreakers holds a circuit breaker for each host.
+// A nil *circuitBreakers, as created for a threshold of 0, disables circuit breaking.
+type circuitBreakers struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+// circuitBreaker stops requests to a host after threshold consecutive transient failures.
+// Once the cooldown has passed, a single trial request is let through: the circuit closes again
+// if it succeeds and stays open for another cooldown if it fails.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool // whether the trial request of a half-open circuit is in flight
+}
+
+func newCircuitBreakers(threshold int, cooldown time.Duration) *circuitBreakers {
+	if threshold <= 0 {
+		return nil
+	}
+	return &circuitBreakers{
+		threshold: threshold,
+		cooldown:  cooldown,
+		breakers:  map[string]*circuitBreaker{},
+	}
+}
+
+// forURL returns the circuit breaker of the host of the URL
+func (b *circuitBreakers) forURL(rawURL string) *circuitBreaker {
+	if b == nil {
+		return nil
+	}
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		host = u.Host
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	breaker, ok := b.breakers[host]
+	if !ok {
+		breaker = &circuitBreaker{threshold: b.threshold, cooldown: b.cooldown, now: time.Now}
+		b.breakers[host] = breaker
+	}
+	return breaker
+}
+
+// allow reports whether a request may be sent
+func (b *circuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.trial || b.now().Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// record records the outcome of a request allowed by allow. Only a success closes the circuit and
+// only a transient failure counts against it; other errors, such as a cancelled request,
+// tell nothing about the host and just let another trial request through.
+func (b *circuitBreaker) record(err error, transient bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	switch {
+	case err == nil:
+		b.failures = 0
+	case transient:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.openedAt = b.now()
+		}
+	}
+}
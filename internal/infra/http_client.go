@@ -2,20 +2,30 @@ package infra
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
+	"net"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal"
 	"github.com/takatori/skg/internal/errors"
 )
 
 type HttpClient struct {
-	Client *http.Client
+	Client   *http.Client
+	auth     Authenticator
+	timeout  time.Duration
+	retry    RetryPolicy
+	breakers *circuitBreakers
 }
 
 type Request struct {
@@ -23,6 +33,12 @@ type Request struct {
 	Headers map[string]string
 	Cookies []http.Cookie
 	IsTrace bool
+	// Idempotent allows the request to be retried on transient failures,
+	// so it must only be set for requests that can safely be sent more than once
+	Idempotent bool
+	// Timeout overrides the client's timeout for each attempt of the request.
+	// The deadline of the request's context, if any, applies to all attempts together.
+	Timeout time.Duration
 }
 
 type PostRequest struct {
@@ -30,121 +46,148 @@ type PostRequest struct {
 	Entity any
 }
 
-func NewHttpClient() *HttpClient {
+// NewHttpClient creates a new HttpClient with the authentication, timeout, retry and circuit breaker
+// policies of the config
+func NewHttpClient(config *internal.Config) (*HttpClient, error) {
+	auth, err := NewAuthenticator(config)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
 
 	dt := http.DefaultTransport
 	transport := dt.(*http.Transport).Clone()
 	transport.MaxIdleConnsPerHost = 10
 	transport.IdleConnTimeout = time.Duration(30) * time.Second
 	transport.MaxIdleConns = transport.MaxIdleConnsPerHost * 2
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
 	return &HttpClient{
+		// Timeouts are applied per request through its context, see send
 		Client: &http.Client{
 			Transport: transport,
-			Timeout:   time.Duration(1) * time.Second,
 		},
-	}
+		auth:    auth,
+		timeout: config.HttpTimeout,
+		retry: RetryPolicy{
+			MaxRetries: config.HttpMaxRetries,
+			BaseDelay:  config.HttpRetryBaseDelay,
+			MaxDelay:   config.HttpRetryMaxDelay,
+		},
+		breakers: newCircuitBreakers(config.CircuitBreakerThreshold, config.CircuitBreakerCooldown),
+	}, nil
 }
 
 func (c *HttpClient) Get(ctx context.Context, req Request, expected any) error {
-	r, err := http.NewRequestWithContext(ctx, http.MethodGet, req.Url, nil)
-	if err != nil {
-		return failure.Translate(
-			err,
-			errors.ErrInternal,
-			failure.Field(failure.Message("failed to create request")),
-			failure.Context{
-				"url": req.Url,
-			},
-		)
-	}
-	for k, v := range req.Headers {
-		if v != "" {
-			r.Header.Set(k, v)
-		}
-	}
-	for _, cookie := range req.Cookies {
-		if len(cookie.Value) > 0 {
-			r.AddCookie(&cookie)
-		}
-	}
+	return c.do(ctx, http.MethodGet, req, nil, "", expected, failure.Context{
+		"url": req.Url,
+	})
+}
 
-	res, err := c.Client.Do(r)
+func (c *HttpClient) Post(ctx context.Context, req PostRequest, expected any) error {
+	encoded, err := json.Marshal(req.Entity)
 	if err != nil {
 		return failure.Translate(
 			err,
 			errors.ErrInternal,
-			failure.Field(failure.Message("failed to send request")),
+			failure.Field(failure.Message("failed to encode request entity")),
 			failure.Context{
-				"url": req.Url,
+				"url":    req.Url,
+				"entity": fmt.Sprintf("%+v", req.Entity),
 			},
 		)
 	}
-	defer res.Body.Close() // TODO: error handling
+	slog.Debug("encoded req body", "body", string(encoded))
 
-	if res.StatusCode != http.StatusOK {
-		return failure.New(
-			errors.ErrInternal,
-			failure.Field(failure.Message("unexpected status code")),
-			failure.Context{
-				"url":  req.Url,
-				"code": fmt.Sprintf("%d", res.StatusCode),
-			},
-		)
-	}
+	return c.do(ctx, http.MethodPost, req.Request, encoded, "application/json", expected, failure.Context{
+		"url": req.Url,
+		"req": string(encoded),
+	})
+}
 
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return failure.Translate(
-			err,
-			errors.ErrInternal,
-			failure.Field(failure.Message("failed to read response body")),
-			failure.Context{
-				"url": req.Url,
-			},
-		)
-	}
+// PostForm sends the form as an application/x-www-form-urlencoded POST request,
+// for parameters too large to send in the URL of a GET request
+func (c *HttpClient) PostForm(ctx context.Context, req Request, form url.Values, expected any) error {
+	return c.do(ctx, http.MethodPost, req, []byte(form.Encode()), "application/x-www-form-urlencoded", expected, failure.Context{
+		"url": req.Url,
+	})
+}
 
-	if err := json.Unmarshal(body, expected); err != nil {
-		return failure.Translate(
-			err,
-			errors.ErrInternal,
-			failure.Field(failure.Message("failed to decode response body")),
-			failure.Context{
-				"url": req.Url,
-			},
-		)
+// do sends the request through the circuit breaker of its host, retrying it on transient failures
+// if it is idempotent, and decodes the JSON response body into expected
+func (c *HttpClient) do(ctx context.Context, method string, req Request, body []byte, contentType string, expected any, errContext failure.Context) error {
+	breaker := c.breakers.forURL(req.Url)
+
+	retries := 0
+	if req.Idempotent {
+		retries = c.retry.MaxRetries
 	}
 
-	return nil
+	for attempt := 0; ; attempt++ {
+		if !breaker.allow() {
+			return failure.New(
+				errors.ErrUnavailable,
+				failure.Field(failure.Message("circuit breaker is open")),
+				errContext,
+			)
+		}
+
+		transient, err := c.send(ctx, method, req, body, contentType, expected, errContext)
+		breaker.record(err, transient)
+		if err == nil || !transient || attempt >= retries {
+			return err
+		}
+
+		delay := c.retry.delay(attempt)
+		slog.Warn("retrying request", "url", req.Url, "attempt", attempt+1, "delay", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
 }
 
-func (c *HttpClient) Post(ctx context.Context, req PostRequest, expected any) error {
-	encoded, err := json.Marshal(req.Entity)
-	if err != nil {
-		return failure.Translate(
-			err,
-			errors.ErrInternal,
-			failure.Field(failure.Message("failed to encode request entity")),
-			failure.Context{
-				"url":    req.Url,
-				"entity": fmt.Sprintf("%+v", req.Entity),
-			},
-		)
+// send makes a single attempt of the request within its timeout, reporting whether a failure is transient:
+// the request could not be sent, timed out or Solr was temporarily unable to answer it
+func (c *HttpClient) send(ctx context.Context, method string, req Request, body []byte, contentType string, expected any, errContext failure.Context) (bool, error) {
+	attemptCtx := ctx
+	if timeout := cmp.Or(req.Timeout, c.timeout); timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
-	slog.Debug("encoded req body: %s", string(encoded))
 
-	r, err := http.NewRequestWithContext(ctx, http.MethodPost, req.Url, bytes.NewBuffer(encoded))
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	r, err := http.NewRequestWithContext(attemptCtx, method, req.Url, reader)
 	if err != nil {
-		return failure.Translate(
+		return false, failure.Translate(
 			err,
 			errors.ErrInternal,
 			failure.Field(failure.Message("failed to create request")),
-			failure.Context{
-				"url": req.Url,
-				"req": string(encoded),
-			},
+			errContext,
 		)
 	}
+	if c.auth != nil {
+		if err := c.auth.Authenticate(r); err != nil {
+			return false, failure.Translate(
+				err,
+				errors.ErrInternal,
+				failure.Field(failure.Message("failed to authenticate request")),
+				errContext,
+			)
+		}
+	}
+	// Headers of the request take precedence over the credentials of the client
 	for k, v := range req.Headers {
 		if v != "" {
 			r.Header.Set(k, v)
@@ -155,68 +198,109 @@ func (c *HttpClient) Post(ctx context.Context, req PostRequest, expected any) er
 			r.AddCookie(&cookie)
 		}
 	}
-	r.Header.Set("Content-Type", "application/json")
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
 
 	res, err := c.Client.Do(r)
 	if err != nil {
-		return failure.Translate(
+		return ctx.Err() == nil, failure.Translate(
 			err,
-			errors.ErrInternal,
+			errorCode(err),
 			failure.Field(failure.Message("failed to send request")),
-			failure.Context{
-				"url": req.Url,
-				"req": string(encoded),
-			},
+			errContext,
 		)
 	}
-	defer res.Body.Close() // TODO: error handling
+	defer func() {
+		if closeErr := res.Body.Close(); closeErr != nil {
+			slog.Warn("failed to close response body", "error", closeErr)
+		}
+	}()
 
 	if res.StatusCode != http.StatusOK {
-		return failure.New(
-			errors.ErrInternal,
-			failure.Field(failure.Message("unexpected status code")),
-			failure.Context{
-				"url":  req.Url,
-				"req":  string(encoded),
-				"code": fmt.Sprintf("%d", res.StatusCode),
-			},
-		)
+		return isTransientStatus(res.StatusCode), statusError(res, errContext)
 	}
 
-	body, err := io.ReadAll(res.Body)
+	resBody, err := io.ReadAll(res.Body)
 	if err != nil {
-		return failure.Translate(
+		return ctx.Err() == nil, failure.Translate(
 			err,
-			errors.ErrInternal,
+			errorCode(err),
 			failure.Field(failure.Message("failed to read response body")),
-			failure.Context{
-				"url": req.Url,
-				"req": string(encoded),
-			},
+			errContext,
 		)
 	}
-	defer func() {
-		if res.Body != nil {
-			if closeErr := res.Body.Close(); closeErr != nil {
-				slog.Warn("failed to close response body: %v", closeErr)
-			}
-		}
-	}()
 
-	slog.Debug("response body: %s", string(body))
+	slog.Debug("response body", "body", string(resBody))
 
-	if err := json.Unmarshal(body, expected); err != nil {
-		return failure.Translate(
+	if err := json.Unmarshal(resBody, expected); err != nil {
+		return false, failure.Translate(
 			err,
 			errors.ErrInternal,
 			failure.Field(failure.Message("failed to decode response body")),
-			failure.Context{
-				"url": req.Url,
-				"req": string(encoded),
-			},
+			errContext,
 		)
 	}
 
-	return nil
+	return false, nil
+}
+
+// maxErrorBodySize limits how much of an error response is read for its message
+const maxErrorBodySize = 64 << 10
+
+// solrErrorResponse is the body of an error response of Solr
+type solrErrorResponse struct {
+	Error struct {
+		Msg  string `json:"msg"`
+		Code int    `json:"code"`
+	} `json:"error"`
+}
+
+// statusError converts a non-200 response into a failure with the error code of its status
+// and the message of Solr's error body, if any
+func statusError(res *http.Response, errContext failure.Context) error {
+	statusContext := maps.Clone(errContext)
+	statusContext["code"] = fmt.Sprintf("%d", res.StatusCode)
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
+	// Drain the rest of the body so that the connection can be reused
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	status, message := res.StatusCode, "unexpected status code"
+	var solrErr solrErrorResponse
+	if err := json.Unmarshal(body, &solrErr); err == nil {
+		if solrErr.Error.Msg != "" {
+			message = solrErr.Error.Msg
+		}
+		if solrErr.Error.Code >= http.StatusBadRequest {
+			status = solrErr.Error.Code
+		}
+	} else if len(body) > 0 {
+		statusContext["body"] = string(body)
+	}
+
+	return failure.New(
+		errors.CodeOfStatus(status),
+		failure.Field(failure.Message(message)),
+		statusContext,
+	)
+}
 
+// errorCode returns errors.ErrTimeout if the error is a timeout, or errors.ErrInternal otherwise
+func errorCode(err error) errors.ErrorCode {
+	var netErr net.Error
+	if stderrors.Is(err, context.DeadlineExceeded) || (stderrors.As(err, &netErr) && netErr.Timeout()) {
+		return errors.ErrTimeout
+	}
+	return errors.ErrInternal
+}
+
+// isTransientStatus reports whether the status code means that Solr was temporarily unable to answer,
+// e.g. while a shard leader is being elected
+func isTransientStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
 }
@@ -0,0 +1,307 @@
+package infra
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/errors"
+)
+
+// flakyServer returns a server that fails the first failures requests with the status,
+// then answers {"ok":true}, counting every request it receives.
+func flakyServer(t *testing.T, failures int, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(requests.Add(1)) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// newTestClient creates a new HttpClient, failing the test on errors
+func newTestClient(t *testing.T, config *internal.Config) *HttpClient {
+	t.Helper()
+	client, err := NewHttpClient(config)
+	if err != nil {
+		t.Fatalf("NewHttpClient() error = %v", err)
+	}
+	return client
+}
+
+func testConfig() *internal.Config {
+	return &internal.Config{
+		HttpMaxRetries:     2,
+		HttpRetryBaseDelay: time.Millisecond,
+		HttpRetryMaxDelay:  5 * time.Millisecond,
+	}
+}
+
+func TestHttpClientRetry(t *testing.T) {
+	tests := []struct {
+		name             string
+		failures         int
+		status           int
+		idempotent       bool
+		expectedOk       bool
+		expectedRequests int32
+	}{
+		{"recovers from transient failures", 2, http.StatusServiceUnavailable, true, true, 3},
+		{"gives up after max retries", 3, http.StatusServiceUnavailable, true, false, 3},
+		{"does not retry client errors", 1, http.StatusBadRequest, true, false, 1},
+		{"does not retry non-idempotent requests", 1, http.StatusServiceUnavailable, false, false, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, requests := flakyServer(t, test.failures, test.status)
+			client := newTestClient(t, testConfig())
+
+			var resp map[string]bool
+			err := client.Get(context.Background(), Request{Url: server.URL, Idempotent: test.idempotent}, &resp)
+			if (err == nil) != test.expectedOk {
+				t.Errorf("Get() error = %v, expected ok %v", err, test.expectedOk)
+			}
+			if requests.Load() != test.expectedRequests {
+				t.Errorf("Get() sent %d requests, expected %d", requests.Load(), test.expectedRequests)
+			}
+		})
+	}
+}
+
+func TestHttpClientRetryPost(t *testing.T) {
+	server, requests := flakyServer(t, 1, http.StatusBadGateway)
+	client := newTestClient(t, testConfig())
+
+	var resp map[string]bool
+	err := client.Post(context.Background(), PostRequest{
+		Request: Request{Url: server.URL, Idempotent: true},
+		Entity:  map[string]string{"q": "*:*"},
+	}, &resp)
+	if err != nil || !resp["ok"] {
+		t.Errorf("Post() = %v, %v, expected ok", resp, err)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("Post() sent %d requests, expected 2", requests.Load())
+	}
+}
+
+func TestHttpClientCircuitBreaker(t *testing.T) {
+	server, requests := flakyServer(t, 3, http.StatusServiceUnavailable)
+	config := testConfig()
+	config.HttpMaxRetries = 0
+	config.CircuitBreakerThreshold = 2
+	config.CircuitBreakerCooldown = time.Minute
+	client := newTestClient(t, config)
+
+	now := time.Now()
+	breaker := client.breakers.forURL(server.URL)
+	breaker.now = func() time.Time { return now }
+
+	get := func() error {
+		var resp map[string]bool
+		return client.Get(context.Background(), Request{Url: server.URL, Idempotent: true}, &resp)
+	}
+
+	// Two consecutive failures open the circuit, which then rejects requests without sending them
+	for i := 0; i < 3; i++ {
+		if err := get(); err == nil {
+			t.Fatalf("Get() #%d expected an error", i)
+		}
+	}
+	if requests.Load() != 2 {
+		t.Errorf("sent %d requests, expected 2 before the circuit opened", requests.Load())
+	}
+
+	// After the cooldown a trial request is let through; its failure opens the circuit again
+	now = now.Add(time.Minute)
+	if err := get(); err == nil {
+		t.Errorf("Get() expected the trial request to fail")
+	}
+	if err := get(); err == nil {
+		t.Errorf("Get() expected the reopened circuit to reject the request")
+	}
+	if requests.Load() != 3 {
+		t.Errorf("sent %d requests, expected 3 after the trial request", requests.Load())
+	}
+
+	// A successful trial request closes the circuit
+	now = now.Add(time.Minute)
+	for i := 0; i < 2; i++ {
+		if err := get(); err != nil {
+			t.Errorf("Get() #%d error = %v after the circuit closed", i, err)
+		}
+	}
+	if requests.Load() != 5 {
+		t.Errorf("sent %d requests, expected 5", requests.Load())
+	}
+}
+
+func TestHttpClientCircuitBreakerCancelledTrial(t *testing.T) {
+	server, requests := flakyServer(t, 3, http.StatusServiceUnavailable)
+	config := testConfig()
+	config.HttpMaxRetries = 0
+	config.CircuitBreakerThreshold = 2
+	config.CircuitBreakerCooldown = time.Minute
+	client := newTestClient(t, config)
+
+	now := time.Now()
+	breaker := client.breakers.forURL(server.URL)
+	breaker.now = func() time.Time { return now }
+
+	get := func(ctx context.Context) error {
+		var resp map[string]bool
+		return client.Get(ctx, Request{Url: server.URL, Idempotent: true}, &resp)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := get(context.Background()); err == nil {
+			t.Fatalf("Get() #%d expected an error", i)
+		}
+	}
+
+	// A cancelled trial request neither closes nor reopens the circuit
+	now = now.Add(time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := get(ctx); err == nil {
+		t.Fatalf("Get() expected the cancelled trial request to fail")
+	}
+
+	// The next request is another trial, whose failure opens the circuit again
+	if err := get(context.Background()); err == nil {
+		t.Errorf("Get() expected the trial request to fail")
+	}
+	if err := get(context.Background()); err == nil {
+		t.Errorf("Get() expected the reopened circuit to reject the request")
+	}
+	if requests.Load() != 3 {
+		t.Errorf("sent %d requests, expected 3", requests.Load())
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond}
+	bounds := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond}
+
+	for attempt, bound := range bounds {
+		for i := 0; i < 100; i++ {
+			if delay := policy.delay(attempt); delay < 0 || delay > bound {
+				t.Fatalf("delay(%d) = %v, expected between 0 and %v", attempt, delay, bound)
+			}
+		}
+	}
+	if delay := (RetryPolicy{}).delay(3); delay != 0 {
+		t.Errorf("delay(3) = %v, expected 0 without a base delay", delay)
+	}
+}
+
+func TestHttpClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	config := &internal.Config{HttpTimeout: 20 * time.Millisecond}
+	client := newTestClient(t, config)
+	var resp map[string]bool
+
+	// The client's timeout applies by default
+	err := client.Get(context.Background(), Request{Url: server.URL}, &resp)
+	if !failure.Is(err, errors.ErrTimeout) {
+		t.Errorf("Get() error = %v, expected %s", err, errors.ErrTimeout)
+	}
+
+	// The request's timeout overrides it
+	err = client.Get(context.Background(), Request{Url: server.URL, Timeout: time.Second}, &resp)
+	if err != nil {
+		t.Errorf("Get() with a longer timeout error = %v", err)
+	}
+
+	// The deadline of the context applies as well
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = client.Get(ctx, Request{Url: server.URL, Timeout: time.Second}, &resp)
+	if !failure.Is(err, errors.ErrTimeout) {
+		t.Errorf("Get() with a context deadline error = %v, expected %s", err, errors.ErrTimeout)
+	}
+}
+
+func TestHttpClientSolrError(t *testing.T) {
+	tests := []struct {
+		name            string
+		status          int
+		body            string
+		expectedCode    errors.ErrorCode
+		expectedMessage string
+	}{
+		{
+			name:            "bad request",
+			status:          http.StatusBadRequest,
+			body:            `{"responseHeader":{"status":400},"error":{"msg":"undefined field title","code":400}}`,
+			expectedCode:    errors.ErrBadRequest,
+			expectedMessage: "undefined field title",
+		},
+		{
+			name:            "not found",
+			status:          http.StatusNotFound,
+			body:            `{"error":{"msg":"Collection not found: books","code":404}}`,
+			expectedCode:    errors.ErrNotFound,
+			expectedMessage: "Collection not found: books",
+		},
+		{
+			name:            "conflict",
+			status:          http.StatusConflict,
+			body:            `{"error":{"msg":"version conflict for doc1","code":409}}`,
+			expectedCode:    errors.ErrConflict,
+			expectedMessage: "version conflict for doc1",
+		},
+		{
+			name:            "unavailable without a body",
+			status:          http.StatusServiceUnavailable,
+			body:            ``,
+			expectedCode:    errors.ErrUnavailable,
+			expectedMessage: "unexpected status code",
+		},
+		{
+			name:            "html error page",
+			status:          http.StatusInternalServerError,
+			body:            `<html><body>Server Error</body></html>`,
+			expectedCode:    errors.ErrInternal,
+			expectedMessage: "unexpected status code",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				_, _ = w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			var resp map[string]bool
+			err := newTestClient(t, &internal.Config{}).Get(context.Background(), Request{Url: server.URL}, &resp)
+			if !failure.Is(err, test.expectedCode) {
+				t.Errorf("Get() error = %v, expected %s", err, test.expectedCode)
+			}
+			if msg := failure.MessageOf(err); msg.String() != test.expectedMessage {
+				t.Errorf("Get() message = %q, expected %q", msg, test.expectedMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package infra
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy configures how idempotent requests are retried on transient failures
+type RetryPolicy struct {
+	MaxRetries int           // number of retries after the first attempt; 0 disables retries
+	BaseDelay  time.Duration // upper bound of the delay before the first retry
+	MaxDelay   time.Duration // upper bound of the delay before any retry
+}
+
+// delay returns the delay before the retry following the given attempt, counted from 0.
+// The bound doubles with each attempt up to MaxDelay, and the delay is drawn uniformly below it
+// ("full jitter") so that clients retrying at the same time spread out.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	bound := p.BaseDelay
+	for i := 0; i < attempt && (p.MaxDelay <= 0 || bound < p.MaxDelay); i++ {
+		bound *= 2
+	}
+	if p.MaxDelay > 0 && bound > p.MaxDelay {
+		bound = p.MaxDelay
+	}
+	if bound <= 0 {
+		return 0
+	}
+	return rand.N(bound + 1)
+}
@@ -0,0 +1,70 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// csvSource reads a document per row of a CSV file, named by its header row
+type csvSource struct {
+	reader  *csv.Reader
+	mapping map[string]string
+	fields  []string // the field of each column, "" for dropped columns
+}
+
+// NewCSVSource creates a Source reading the rows of a CSV file from r as documents.
+// The header row names the field of each column, renamed by the mapping (see SourceOptions.FieldMapping).
+// Values are strings, and empty values are left out of the documents.
+func NewCSVSource(r io.Reader, mapping map[string]string) Source {
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.ReuseRecord = true
+	return &csvSource{reader: reader, mapping: mapping}
+}
+
+// Next implements Source
+func (s *csvSource) Next() (Document, error) {
+	if s.fields == nil {
+		if err := s.readHeader(); err != nil {
+			return nil, err
+		}
+	}
+
+	record, err := s.reader.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	doc := Document{}
+	for i, value := range record {
+		if s.fields[i] != "" && value != "" {
+			doc[s.fields[i]] = value
+		}
+	}
+	return doc, nil
+}
+
+// readHeader reads the header row and resolves the field of each column
+func (s *csvSource) readHeader() error {
+	header, err := s.reader.Read()
+	if err == io.EOF {
+		return fmt.Errorf("CSV file has no header row")
+	}
+	if err != nil {
+		return fmt.Errorf("invalid CSV header: %w", err)
+	}
+
+	s.fields = make([]string, len(header))
+	for i, column := range header {
+		field := column
+		if mapped, ok := s.mapping[column]; ok {
+			field = mapped
+		}
+		s.fields[i] = field
+	}
+	return nil
+}
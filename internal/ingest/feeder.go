@@ -0,0 +1,306 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/errors"
+	"github.com/takatori/skg/internal/infra"
+)
+
+// Options controls how a Feeder sends documents to Solr
+type Options struct {
+	BatchSize   int // documents per update request
+	Concurrency int // update requests sent at the same time
+	// CommitWithin, if positive, asks Solr to commit each batch within this duration
+	// instead of committing once after every batch has been sent
+	CommitWithin time.Duration
+	// Transform, if set, is applied to each document before it is sent, e.g. a compiled Pipeline
+	Transform Transform
+	// Progress, if set, is called with a copy of the result each time a batch has been sent and once committed.
+	// Calls are serialized, and block the feed until they return.
+	Progress func(Result)
+}
+
+// DocumentError reports a document that could not be transformed and was not sent
+type DocumentError struct {
+	Offset int64  `json:"offset"` // the index of the document in the input
+	Error  string `json:"error"`
+}
+
+// BatchError reports a batch that Solr failed to index
+type BatchError struct {
+	Batch  int    `json:"batch"`  // the index of the batch, counted from 0
+	Offset int64  `json:"offset"` // the index of the first document of the batch in the input
+	Size   int    `json:"size"`
+	Error  string `json:"error"`
+}
+
+// maxRecordedErrors is how many failed batches and rejected documents a Result lists at most
+const maxRecordedErrors = 100
+
+// Result summarizes a feed
+type Result struct {
+	Documents       int64 `json:"documents"` // documents in batches indexed successfully
+	Batches         int   `json:"batches"`
+	BatchesFailed   int   `json:"batchesFailed"`
+	DocumentsFailed int64 `json:"documentsFailed"` // documents in failed batches or rejected by the transform
+	// FailedBatches and FailedDocuments list the first failures, up to maxRecordedErrors of each
+	FailedBatches   []BatchError    `json:"failedBatches"`
+	FailedDocuments []DocumentError `json:"failedDocuments"`
+	Committed       bool            `json:"committed"`
+}
+
+// addBatchError counts the failed batch, listing it unless enough failures are listed
+func (r *Result) addBatchError(e BatchError) {
+	r.BatchesFailed++
+	r.DocumentsFailed += int64(e.Size)
+	if len(r.FailedBatches) < maxRecordedErrors {
+		r.FailedBatches = append(r.FailedBatches, e)
+	}
+}
+
+// addDocumentError counts the rejected document, listing it unless enough failures are listed
+func (r *Result) addDocumentError(e DocumentError) {
+	r.DocumentsFailed++
+	if len(r.FailedDocuments) < maxRecordedErrors {
+		r.FailedDocuments = append(r.FailedDocuments, e)
+	}
+}
+
+// clone returns a copy of the result that does not share its slices
+func (r Result) clone() Result {
+	r.FailedBatches = slices.Clone(r.FailedBatches)
+	r.FailedDocuments = slices.Clone(r.FailedDocuments)
+	return r
+}
+
+// progress is the result of a feed, updated by its reader and workers
+type progress struct {
+	mu     sync.Mutex
+	result Result
+	report func(Result)
+}
+
+// record applies the change to the result without reporting it
+func (p *progress) record(change func(*Result)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	change(&p.result)
+}
+
+// update applies the change to the result and reports it
+func (p *progress) update(change func(*Result)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	change(&p.result)
+	if p.report != nil {
+		p.report(p.result.clone())
+	}
+}
+
+// Feeder streams documents from a Source to Solr's update API in batches
+type Feeder struct {
+	config     *internal.Config
+	httpClient *infra.HttpClient
+}
+
+// batch is a batch of documents read from a Source
+type batch struct {
+	index  int
+	offset int64
+	docs   []Document
+}
+
+// NewFeeder creates a new Feeder with the given config and HTTP client
+func NewFeeder(config *internal.Config, httpClient *infra.HttpClient) *Feeder {
+	return &Feeder{
+		config:     config,
+		httpClient: httpClient,
+	}
+}
+
+// DefaultOptions returns the options configured in the config
+func DefaultOptions(config *internal.Config) Options {
+	return Options{
+		BatchSize:   config.FeedBatchSize,
+		Concurrency: config.FeedConcurrency,
+	}
+}
+
+// Feed reads every document of the source and indexes them into the collection, holding at most
+// Concurrency+1 batches in memory. Batches that Solr fails to index and documents that the transform
+// rejects are reported in the result rather than stopping the feed; an error is returned if the source
+// cannot be read, along with the result of the batches sent until then, or if the final commit fails.
+func (f *Feeder) Feed(ctx context.Context, collection string, source Source, opts Options) (Result, error) {
+	if opts.BatchSize < 1 || opts.Concurrency < 1 {
+		return Result{}, failure.New(
+			errors.ErrBadRequest,
+			failure.Field(failure.Messagef("batch size and concurrency must be positive: %d, %d", opts.BatchSize, opts.Concurrency)),
+		)
+	}
+
+	var (
+		p = &progress{
+			result: Result{FailedBatches: []BatchError{}, FailedDocuments: []DocumentError{}},
+			report: opts.Progress,
+		}
+		wg sync.WaitGroup
+	)
+	batches := make(chan batch)
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				err := f.send(ctx, collection, b.docs, opts.CommitWithin)
+
+				p.update(func(result *Result) {
+					if err != nil {
+						result.addBatchError(BatchError{
+							Batch:  b.index,
+							Offset: b.offset,
+							Size:   len(b.docs),
+							Error:  errorMessage(err),
+						})
+					} else {
+						result.Documents += int64(len(b.docs))
+					}
+				})
+			}
+		}()
+	}
+
+	readErr := readBatches(ctx, source, opts, batches, p)
+	close(batches)
+	wg.Wait()
+
+	result := p.result
+	if readErr != nil {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		return result, failure.Translate(
+			readErr,
+			errors.ErrBadRequest,
+			failure.Field(failure.Messagef("failed to read documents: %v", readErr)),
+		)
+	}
+
+	if opts.CommitWithin <= 0 && result.Documents > 0 {
+		if err := f.commit(ctx, collection); err != nil {
+			return result, fmt.Errorf("failed to commit: %w", err)
+		}
+		p.update(func(result *Result) { result.Committed = true })
+	}
+	return p.result, nil
+}
+
+// readBatches reads and transforms the documents of the source in batches, counting the batches and
+// recording the rejected documents in the progress, which is reported as the batches are sent
+func readBatches(ctx context.Context, source Source, opts Options, batches chan<- batch, p *progress) error {
+	var (
+		offset, index int64
+		count         int
+	)
+	docs := make([]Document, 0, opts.BatchSize)
+	flush := func() error {
+		select {
+		case batches <- batch{index: count, offset: offset, docs: docs}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		count++
+		p.record(func(result *Result) { result.Batches = count })
+		docs = make([]Document, 0, opts.BatchSize)
+		return nil
+	}
+
+	for ; ; index++ {
+		doc, err := source.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("document %d: %w", index, err)
+		}
+		if opts.Transform != nil {
+			if doc, err = opts.Transform(doc); err != nil {
+				p.record(func(result *Result) {
+					result.addDocumentError(DocumentError{
+						Offset: index,
+						Error:  errorMessage(err),
+					})
+				})
+				continue
+			}
+		}
+		if len(docs) == 0 {
+			offset = index
+		}
+		docs = append(docs, doc)
+		if len(docs) == opts.BatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(docs) > 0 {
+		return flush()
+	}
+	return nil
+}
+
+// send indexes a batch of documents
+func (f *Feeder) send(ctx context.Context, collection string, docs []Document, commitWithin time.Duration) error {
+	url := fmt.Sprintf("%s/%s/update", f.config.SolrUrl, collection)
+	if commitWithin > 0 {
+		url = fmt.Sprintf("%s?commitWithin=%d", url, commitWithin.Milliseconds())
+	}
+
+	var resp map[string]any
+	return f.httpClient.Post(
+		ctx,
+		infra.PostRequest{
+			Request: infra.Request{
+				Url:     url,
+				Timeout: f.config.FeedTimeout,
+			},
+			Entity: docs,
+		},
+		&resp,
+	)
+}
+
+// commit commits the documents indexed into the collection
+func (f *Feeder) commit(ctx context.Context, collection string) error {
+	var resp map[string]any
+	return f.httpClient.Post(
+		ctx,
+		infra.PostRequest{
+			Request: infra.Request{
+				Url:        fmt.Sprintf("%s/%s/update", f.config.SolrUrl, collection),
+				Timeout:    f.config.FeedTimeout,
+				Idempotent: true,
+			},
+			Entity: map[string]any{"commit": map[string]any{}},
+		},
+		&resp,
+	)
+}
+
+// errorMessage returns the failure message of the error, or the error itself
+func errorMessage(err error) string {
+	if msg := failure.MessageOf(err); msg != "" {
+		return msg.String()
+	}
+	return err.Error()
+}
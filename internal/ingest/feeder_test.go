@@ -0,0 +1,215 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/infra"
+)
+
+// fakeSolr is an update handler that indexes documents in memory
+// and rejects batches containing a document with "fail" set
+type fakeSolr struct {
+	mu      sync.Mutex
+	ids     []string
+	commits int
+	queries []string
+}
+
+func (s *fakeSolr) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries = append(s.queries, r.URL.RawQuery)
+
+	var body any
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, ok := body.(map[string]any); ok {
+		s.commits++
+		_, _ = w.Write([]byte(`{}`))
+		return
+	}
+	docs := body.([]any)
+	for _, doc := range docs {
+		if doc.(map[string]any)["fail"] != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"msg":"ERROR: [doc=x] unknown field 'fail'","code":400}}`))
+			return
+		}
+	}
+	for _, doc := range docs {
+		s.ids = append(s.ids, doc.(map[string]any)["id"].(string))
+	}
+	_, _ = w.Write([]byte(`{}`))
+}
+
+// newTestFeeder returns a Feeder sending documents to a fakeSolr
+func newTestFeeder(t *testing.T) (*Feeder, *fakeSolr) {
+	t.Helper()
+	solr := &fakeSolr{}
+	server := httptest.NewServer(solr)
+	t.Cleanup(server.Close)
+
+	config := &internal.Config{SolrUrl: server.URL}
+	httpClient, err := infra.NewHttpClient(config)
+	if err != nil {
+		t.Fatalf("NewHttpClient() error = %v", err)
+	}
+	return NewFeeder(config, httpClient), solr
+}
+
+// documents returns a JSON array of n documents, with "fail" set on the documents of the given indexes
+func documents(n int, failing ...int) string {
+	docs := make([]string, n)
+	for i := range docs {
+		docs[i] = fmt.Sprintf(`{"id":"%d"}`, i)
+		for _, f := range failing {
+			if f == i {
+				docs[i] = fmt.Sprintf(`{"id":"%d","fail":true}`, i)
+			}
+		}
+	}
+	return "[" + strings.Join(docs, ",") + "]"
+}
+
+func TestFeederFeed(t *testing.T) {
+	feeder, solr := newTestFeeder(t)
+
+	result, err := feeder.Feed(context.Background(), "products", NewJSONSource(strings.NewReader(documents(25, 12))), Options{BatchSize: 10, Concurrency: 3})
+	if err != nil {
+		t.Fatalf("Feed() error = %v", err)
+	}
+
+	if result.Documents != 15 || result.Batches != 3 || !result.Committed {
+		t.Errorf("Feed() = %+v, expected 15 documents in 3 batches, committed", result)
+	}
+	expectedFailure := BatchError{Batch: 1, Offset: 10, Size: 10, Error: "ERROR: [doc=x] unknown field 'fail'"}
+	if len(result.FailedBatches) != 1 || result.FailedBatches[0] != expectedFailure {
+		t.Errorf("Feed() failed batches = %+v, expected %+v", result.FailedBatches, expectedFailure)
+	}
+
+	if len(solr.ids) != 15 || slices.Contains(solr.ids, "12") {
+		t.Errorf("indexed %v, expected the documents of batches 0 and 2", solr.ids)
+	}
+	if solr.commits != 1 {
+		t.Errorf("committed %d times, expected once", solr.commits)
+	}
+}
+
+func TestFeederFeedCommitWithin(t *testing.T) {
+	feeder, solr := newTestFeeder(t)
+
+	result, err := feeder.Feed(context.Background(), "products", NewJSONSource(strings.NewReader(documents(5))), Options{BatchSize: 2, Concurrency: 1, CommitWithin: 3 * time.Second})
+	if err != nil {
+		t.Fatalf("Feed() error = %v", err)
+	}
+	if result.Documents != 5 || result.Batches != 3 || result.Committed {
+		t.Errorf("Feed() = %+v, expected 5 documents in 3 batches, not committed", result)
+	}
+	if solr.commits != 0 {
+		t.Errorf("committed %d times, expected no explicit commit", solr.commits)
+	}
+	for _, query := range solr.queries {
+		if query != "commitWithin=3000" {
+			t.Errorf("update query = %q, expected commitWithin=3000", query)
+		}
+	}
+}
+
+func TestFeederFeedInvalidInput(t *testing.T) {
+	feeder, solr := newTestFeeder(t)
+
+	// The batches read before the invalid document are still sent
+	result, err := feeder.Feed(context.Background(), "products", NewJSONSource(strings.NewReader(`[{"id":"0"},{"id":"1"},{"id":"2"},oops]`)), Options{BatchSize: 2, Concurrency: 1})
+	if err == nil {
+		t.Fatalf("Feed() expected an error for invalid input")
+	}
+	if result.Documents != 2 || result.Committed {
+		t.Errorf("Feed() = %+v, expected 2 documents, not committed", result)
+	}
+	if solr.commits != 0 {
+		t.Errorf("committed %d times, expected no commit", solr.commits)
+	}
+
+	if _, err := feeder.Feed(context.Background(), "products", NewJSONSource(strings.NewReader(`[]`)), Options{}); err == nil {
+		t.Errorf("Feed() expected an error for invalid options")
+	}
+}
+
+func TestFeederFeedTransform(t *testing.T) {
+	feeder, solr := newTestFeeder(t)
+
+	p := Pipeline{Name: "test", Steps: []Step{
+		{Op: OpRename, From: "key", To: "id"},
+		{Op: OpCoerce, Fields: []string{"id"}, Type: "string"},
+		{Op: OpCoerce, Fields: []string{"stock"}, Type: "int"},
+	}}
+	transform, err := p.Compile(newTestAnalyzer(t))
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	input := `[{"key":0},{"key":1,"stock":"none"},{"key":2},{"key":3,"stock":"5"}]`
+	result, err := feeder.Feed(context.Background(), "products", NewJSONSource(strings.NewReader(input)), Options{BatchSize: 2, Concurrency: 1, Transform: transform})
+	if err != nil {
+		t.Fatalf("Feed() error = %v", err)
+	}
+
+	if result.Documents != 3 || result.Batches != 2 {
+		t.Errorf("Feed() = %+v, expected 3 documents in 2 batches", result)
+	}
+	if len(result.FailedDocuments) != 1 || result.FailedDocuments[0].Offset != 1 {
+		t.Errorf("Feed() failed documents = %+v, expected document 1", result.FailedDocuments)
+	}
+	if expected := []string{"0", "2", "3"}; !reflect.DeepEqual(solr.ids, expected) {
+		t.Errorf("indexed %v, expected %v", solr.ids, expected)
+	}
+}
+
+func TestFeederFeedManyFailures(t *testing.T) {
+	feeder, solr := newTestFeeder(t)
+
+	// Every other document is rejected
+	transform := func(doc Document) (Document, error) {
+		var id int
+		fmt.Sscan(doc["id"].(string), &id)
+		if id%2 == 1 {
+			return nil, fmt.Errorf("odd id %d", id)
+		}
+		return doc, nil
+	}
+	reports := 0
+	progress := func(Result) { reports++ }
+
+	n := 4 * maxRecordedErrors
+	result, err := feeder.Feed(context.Background(), "products", NewJSONSource(strings.NewReader(documents(n))), Options{BatchSize: 10, Concurrency: 1, Transform: transform, Progress: progress})
+	if err != nil {
+		t.Fatalf("Feed() error = %v", err)
+	}
+
+	if result.Documents != int64(n/2) || result.DocumentsFailed != int64(n/2) {
+		t.Errorf("Feed() = %d documents, %d failed, expected %d of each", result.Documents, result.DocumentsFailed, n/2)
+	}
+	if len(result.FailedDocuments) != maxRecordedErrors || result.FailedDocuments[0].Offset != 1 {
+		t.Errorf("Feed() listed %d failed documents from %+v, expected the first %d", len(result.FailedDocuments), result.FailedDocuments[0], maxRecordedErrors)
+	}
+	// One report per batch, and one for the commit
+	if expected := result.Batches + 1; reports != expected {
+		t.Errorf("Progress called %d times, expected %d", reports, expected)
+	}
+	if len(solr.ids) != n/2 {
+		t.Errorf("indexed %d documents, expected %d", len(solr.ids), n/2)
+	}
+}
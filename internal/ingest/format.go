@@ -0,0 +1,95 @@
+package ingest
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+// Format is the format of a file of documents
+type Format string
+
+const (
+	// FormatJSON is a JSON array of documents, or a single document
+	FormatJSON Format = "json"
+	// FormatJSONL is one JSON document per line (JSON Lines)
+	FormatJSONL Format = "jsonl"
+	// FormatCSV is a CSV file with a header row naming the field of each column
+	FormatCSV Format = "csv"
+	// FormatParquet is an Apache Parquet file with a row per document
+	FormatParquet Format = "parquet"
+)
+
+// File is an uploaded file of documents.
+// Parquet files are read at random offsets, the other formats sequentially.
+type File interface {
+	io.Reader
+	io.ReaderAt
+}
+
+// SourceOptions controls how documents are read from a file
+type SourceOptions struct {
+	// FieldMapping renames CSV columns from their header to a field name; columns mapped to ""
+	// are dropped and columns not in the mapping keep their header as field name
+	FieldMapping map[string]string
+}
+
+// extensionFormats maps file extensions to formats
+var extensionFormats = map[string]Format{
+	".json":    FormatJSON,
+	".jsonl":   FormatJSONL,
+	".ndjson":  FormatJSONL,
+	".csv":     FormatCSV,
+	".parquet": FormatParquet,
+}
+
+// contentTypeFormats maps media types to formats
+var contentTypeFormats = map[string]Format{
+	"application/json":               FormatJSON,
+	"application/jsonl":              FormatJSONL,
+	"application/x-ndjson":           FormatJSONL,
+	"application/x-jsonlines":        FormatJSONL,
+	"text/csv":                       FormatCSV,
+	"application/vnd.apache.parquet": FormatParquet,
+	"application/x-parquet":          FormatParquet,
+}
+
+// ParseFormat parses the name of a format
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case FormatJSON, FormatJSONL, FormatCSV, FormatParquet:
+		return format, nil
+	}
+	return "", fmt.Errorf("unsupported format: %q", name)
+}
+
+// DetectFormat detects the format of a file from its name, or from its content type
+// if the extension is unknown. Files that match neither are read as FormatJSON.
+func DetectFormat(filename, contentType string) Format {
+	if format, ok := extensionFormats[strings.ToLower(filepath.Ext(filename))]; ok {
+		return format
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if format, ok := contentTypeFormats[mediaType]; ok {
+			return format
+		}
+	}
+	return FormatJSON
+}
+
+// NewSource creates a Source reading the documents of a file of the given format and size
+func NewSource(format Format, file File, size int64, opts SourceOptions) (Source, error) {
+	switch format {
+	case FormatJSON:
+		return NewJSONSource(file), nil
+	case FormatJSONL:
+		return NewJSONLSource(file), nil
+	case FormatCSV:
+		return NewCSVSource(file, opts.FieldMapping), nil
+	case FormatParquet:
+		return NewParquetSource(file, size)
+	}
+	return nil, fmt.Errorf("unsupported format: %q", format)
+}
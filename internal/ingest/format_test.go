@@ -0,0 +1,135 @@
+package ingest
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		filename    string
+		contentType string
+		expected    Format
+	}{
+		{"products.json", "", FormatJSON},
+		{"products.JSONL", "application/octet-stream", FormatJSONL},
+		{"products.ndjson", "", FormatJSONL},
+		{"products.csv", "application/json", FormatCSV},
+		{"products.parquet", "", FormatParquet},
+		{"products", "text/csv; charset=utf-8", FormatCSV},
+		{"products", "application/x-ndjson", FormatJSONL},
+		{"products", "application/vnd.apache.parquet", FormatParquet},
+		{"products.txt", "text/plain", FormatJSON},
+	}
+
+	for _, test := range tests {
+		if result := DetectFormat(test.filename, test.contentType); result != test.expected {
+			t.Errorf("DetectFormat(%q, %q) = %q, expected %q", test.filename, test.contentType, result, test.expected)
+		}
+	}
+
+	if format, err := ParseFormat("CSV"); err != nil || format != FormatCSV {
+		t.Errorf("ParseFormat(%q) = %q, %v, expected %q", "CSV", format, err, FormatCSV)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Errorf("ParseFormat(%q) expected an error", "xml")
+	}
+}
+
+func TestNewSource(t *testing.T) {
+	type product struct {
+		ID    string   `parquet:"id"`
+		Title string   `parquet:"title,optional"`
+		Price float64  `parquet:"price"`
+		Tags  []string `parquet:"tags,list"`
+	}
+	var parquetFile bytes.Buffer
+	if err := parquet.Write(&parquetFile, []product{
+		{ID: "1", Title: "機械学習入門", Price: 2800, Tags: []string{"ml", "ai"}},
+		{ID: "2", Price: 1200, Tags: []string{}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		format     Format
+		input      []byte
+		opts       SourceOptions
+		expected   []Document
+		expectedOk bool
+	}{
+		{
+			name:       "jsonl",
+			format:     FormatJSONL,
+			input:      []byte("{\"id\":\"1\",\"title\":\"機械学習\"}\n\n{\"id\":\"2\"}\n"),
+			expected:   []Document{{"id": "1", "title": "機械学習"}, {"id": "2"}},
+			expectedOk: true,
+		},
+		{
+			name:       "invalid jsonl",
+			format:     FormatJSONL,
+			input:      []byte("{\"id\":\"1\"}\n[1]\n"),
+			expected:   []Document{{"id": "1"}},
+			expectedOk: false,
+		},
+		{
+			name:   "csv",
+			format: FormatCSV,
+			input:  []byte("ID,Title,Internal,price\n1,\"機械学習, 入門\",x,2800\n2,,y,1200\n"),
+			opts:   SourceOptions{FieldMapping: map[string]string{"ID": "id", "Title": "title_ja", "Internal": ""}},
+			expected: []Document{
+				{"id": "1", "title_ja": "機械学習, 入門", "price": "2800"},
+				{"id": "2", "price": "1200"},
+			},
+			expectedOk: true,
+		},
+		{
+			name:       "csv with a short row",
+			format:     FormatCSV,
+			input:      []byte("id,title\n1,a\n2\n"),
+			expected:   []Document{{"id": "1", "title": "a"}},
+			expectedOk: false,
+		},
+		{
+			name:       "empty csv",
+			format:     FormatCSV,
+			input:      []byte(""),
+			expectedOk: false,
+		},
+		{
+			name:   "parquet",
+			format: FormatParquet,
+			input:  parquetFile.Bytes(),
+			expected: []Document{
+				{"id": "1", "title": "機械学習入門", "price": 2800.0, "tags": []any{"ml", "ai"}},
+				{"id": "2", "price": 1200.0, "tags": []any{}},
+			},
+			expectedOk: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source, err := NewSource(test.format, bytes.NewReader(test.input), int64(len(test.input)), test.opts)
+			if err != nil {
+				t.Fatalf("NewSource() error = %v", err)
+			}
+			docs, err := readAll(source)
+			if (err == nil) != test.expectedOk {
+				t.Errorf("Next() error = %v, expected ok %v", err, test.expectedOk)
+			}
+			if !reflect.DeepEqual(docs, test.expected) {
+				t.Errorf("Next() = %v, expected %v", docs, test.expected)
+			}
+		})
+	}
+
+	if _, err := NewSource(FormatParquet, strings.NewReader("not parquet"), 11, SourceOptions{}); err == nil {
+		t.Errorf("NewSource() expected an error for an invalid Parquet file")
+	}
+}
@@ -0,0 +1,278 @@
+package ingest
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/errors"
+)
+
+// JobState is the state of a feed job
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+// Finished reports whether the job has stopped and will not change anymore
+func (s JobState) Finished() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCancelled
+}
+
+// Job is a feed running in the background, with the result of the batches sent so far
+type Job struct {
+	ID         string     `json:"id"`
+	Collection string     `json:"collection"`
+	State      JobState   `json:"state"`
+	Error      string     `json:"error,omitempty"` // why the job failed, if it did
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Result
+}
+
+// Elapsed returns how long the job has been running, or ran if it has finished
+func (j Job) Elapsed(now time.Time) time.Duration {
+	switch {
+	case j.StartedAt == nil:
+		return 0
+	case j.FinishedAt != nil:
+		return j.FinishedAt.Sub(*j.StartedAt)
+	default:
+		return now.Sub(*j.StartedAt)
+	}
+}
+
+// JobStore keeps the state of feed jobs
+type JobStore interface {
+	// Save adds the job or replaces the job with the same ID
+	Save(job Job) error
+	// Get returns the job with the ID, or an errors.ErrNotFound failure
+	Get(id string) (Job, error)
+	// List returns every job, most recently created first
+	List() ([]Job, error)
+}
+
+// jobTask is a queued job with the documents it feeds
+type jobTask struct {
+	id      string
+	ctx     context.Context
+	source  Source
+	opts    Options
+	release func()
+}
+
+// JobRunner runs feed jobs in the background on a bounded pool of workers
+type JobRunner struct {
+	feeder *Feeder
+	store  JobStore
+	queue  chan jobTask
+
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+	// mu guards cancels, and the transitions of jobs out of the queued state
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc // the cancellation of each queued or running job
+}
+
+// NewJobRunner creates a JobRunner and starts the workers configured in the config
+func NewJobRunner(config *internal.Config, feeder *Feeder, store JobStore) *JobRunner {
+	ctx, stop := context.WithCancel(context.Background())
+	r := &JobRunner{
+		feeder:  feeder,
+		store:   store,
+		queue:   make(chan jobTask, max(config.FeedJobQueueSize, 0)),
+		ctx:     ctx,
+		stop:    stop,
+		cancels: map[string]context.CancelFunc{},
+	}
+	for i := 0; i < max(config.FeedJobWorkers, 1); i++ {
+		r.wg.Add(1)
+		go r.work()
+	}
+	return r
+}
+
+// Submit queues a job feeding the documents of the source into the collection and returns it.
+// release is called once the job no longer needs the source, even if it cannot be queued.
+func (r *JobRunner) Submit(collection string, source Source, opts Options, release func()) (Job, error) {
+	if r.ctx.Err() != nil {
+		release()
+		return Job{}, failure.New(errors.ErrUnavailable, failure.Field(failure.Message("feed jobs are shutting down")))
+	}
+
+	job := Job{
+		ID:         uuid.NewString(),
+		Collection: collection,
+		State:      JobQueued,
+		CreatedAt:  time.Now(),
+		Result:     Result{FailedBatches: []BatchError{}, FailedDocuments: []DocumentError{}},
+	}
+	if err := r.store.Save(job); err != nil {
+		release()
+		return Job{}, err
+	}
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.mu.Lock()
+	r.cancels[job.ID] = cancel
+	r.mu.Unlock()
+
+	select {
+	case r.queue <- jobTask{id: job.ID, ctx: ctx, source: source, opts: opts, release: release}:
+		return job, nil
+	default:
+	}
+
+	r.forget(job.ID)
+	release()
+	finish(&job, JobFailed, "too many feed jobs are queued")
+	r.save(job)
+	return Job{}, failure.New(
+		errors.ErrUnavailable,
+		failure.Field(failure.Message("too many feed jobs are queued")),
+	)
+}
+
+// Get returns the job with the ID
+func (r *JobRunner) Get(id string) (Job, error) {
+	return r.store.Get(id)
+}
+
+// List returns every job, most recently created first
+func (r *JobRunner) List() ([]Job, error) {
+	return r.store.List()
+}
+
+// Cancel stops the job with the ID if it is queued or running. A running job is cancelled once
+// its batches being sent finish, so it may still be running when Cancel returns.
+func (r *JobRunner) Cancel(id string) (Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, err := r.store.Get(id)
+	if err != nil {
+		return Job{}, err
+	}
+	cancel, ok := r.cancels[id]
+	if !ok {
+		return Job{}, failure.New(
+			errors.ErrConflict,
+			failure.Field(failure.Messagef("job %s is already %s", id, job.State)),
+		)
+	}
+	cancel()
+
+	if job.State == JobQueued {
+		finish(&job, JobCancelled, "")
+		r.save(job)
+	}
+	return job, nil
+}
+
+// Close cancels every job and waits for the workers to stop
+func (r *JobRunner) Close() {
+	r.stop()
+	r.wg.Wait()
+}
+
+// work runs the queued jobs until the runner is closed, then releases the jobs left in the queue
+func (r *JobRunner) work() {
+	defer r.wg.Done()
+	for {
+		select {
+		case task := <-r.queue:
+			r.run(task)
+		case <-r.ctx.Done():
+			for {
+				select {
+				case task := <-r.queue:
+					r.run(task)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// run runs the job of the task, saving its progress to the store
+func (r *JobRunner) run(task jobTask) {
+	defer task.release()
+	defer r.forget(task.id)
+
+	r.mu.Lock()
+	job, err := r.store.Get(task.id)
+	if err != nil {
+		r.mu.Unlock()
+		slog.Error("failed to get job", "job", task.id, "error", err)
+		return
+	}
+	if task.ctx.Err() != nil {
+		if !job.State.Finished() {
+			finish(&job, JobCancelled, "")
+			r.save(job)
+		}
+		r.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	job.State = JobRunning
+	job.StartedAt = &now
+	r.save(job)
+	r.mu.Unlock()
+
+	// Progress is reported serially once per batch, and not after Feed returns
+	opts := task.opts
+	opts.Progress = func(result Result) {
+		job.Result = result
+		r.save(job)
+	}
+	result, err := r.feeder.Feed(task.ctx, job.Collection, task.source, opts)
+
+	job.Result = result
+	switch {
+	case err == nil:
+		finish(&job, JobSucceeded, "")
+	case task.ctx.Err() != nil:
+		finish(&job, JobCancelled, "")
+	default:
+		finish(&job, JobFailed, errorMessage(err))
+	}
+	r.save(job)
+}
+
+// save saves the job, logging failures as the job keeps running without its state
+func (r *JobRunner) save(job Job) {
+	if err := r.store.Save(job); err != nil {
+		slog.Warn("failed to save job", "job", job.ID, "error", err)
+	}
+}
+
+// forget removes the cancellation of a job that is no longer queued or running
+func (r *JobRunner) forget(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cancel, ok := r.cancels[id]; ok {
+		cancel()
+		delete(r.cancels, id)
+	}
+}
+
+// finish sets the final state of the job
+func finish(job *Job, state JobState, message string) {
+	now := time.Now()
+	job.State = state
+	job.Error = message
+	job.FinishedAt = &now
+}
@@ -0,0 +1,86 @@
+package ingest
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal/errors"
+)
+
+// MemoryJobStore is a JobStore keeping jobs in memory, forgetting finished jobs after a retention period
+type MemoryJobStore struct {
+	mu        sync.RWMutex
+	jobs      map[string]Job
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewMemoryJobStore creates a MemoryJobStore keeping finished jobs for the retention period;
+// a retention of 0 keeps them until the server stops
+func NewMemoryJobStore(retention time.Duration) *MemoryJobStore {
+	return &MemoryJobStore{
+		jobs:      map[string]Job{},
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// Save implements JobStore
+func (s *MemoryJobStore) Save(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[job.ID] = job
+	if job.FinishedAt != nil {
+		s.expire()
+	}
+	return nil
+}
+
+// Get implements JobStore
+func (s *MemoryJobStore) Get(id string) (Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, ok := s.jobs[id]
+	if !ok || s.expired(job) {
+		return Job{}, failure.New(
+			errors.ErrNotFound,
+			failure.Field(failure.Messagef("job %s not found", id)),
+		)
+	}
+	return job, nil
+}
+
+// List implements JobStore
+func (s *MemoryJobStore) List() ([]Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		if !s.expired(job) {
+			jobs = append(jobs, job)
+		}
+	}
+	slices.SortFunc(jobs, func(a, b Job) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return jobs, nil
+}
+
+// expire removes the jobs past their retention; s.mu must be held for writing
+func (s *MemoryJobStore) expire() {
+	for id, job := range s.jobs {
+		if s.expired(job) {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// expired reports whether the job finished longer than the retention period ago
+func (s *MemoryJobStore) expired(job Job) bool {
+	return s.retention > 0 && job.FinishedAt != nil && s.now().Sub(*job.FinishedAt) > s.retention
+}
@@ -0,0 +1,171 @@
+package ingest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/errors"
+	"github.com/takatori/skg/internal/infra"
+)
+
+// waitJob waits until the job has finished and returns it
+func waitJob(t *testing.T, runner *JobRunner, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := runner.Get(id)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if job.State.Finished() {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return Job{}
+}
+
+// newBlockingRunner returns a runner with one worker whose update requests block until unblock is closed
+func newBlockingRunner(t *testing.T, queueSize int) (runner *JobRunner, started <-chan struct{}, unblock chan struct{}) {
+	t.Helper()
+	unblock = make(chan struct{})
+	requests := make(chan struct{}, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- struct{}{}
+		select {
+		case <-unblock:
+		case <-r.Context().Done():
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	config := &internal.Config{SolrUrl: server.URL, FeedJobWorkers: 1, FeedJobQueueSize: queueSize}
+	httpClient, err := infra.NewHttpClient(config)
+	if err != nil {
+		t.Fatalf("NewHttpClient() error = %v", err)
+	}
+	runner = NewJobRunner(config, NewFeeder(config, httpClient), NewMemoryJobStore(0))
+	t.Cleanup(runner.Close)
+	return runner, requests, unblock
+}
+
+func TestJobRunner(t *testing.T) {
+	feeder, solr := newTestFeeder(t)
+	runner := NewJobRunner(&internal.Config{FeedJobWorkers: 2, FeedJobQueueSize: 4}, feeder, NewMemoryJobStore(time.Hour))
+	t.Cleanup(runner.Close)
+
+	released := make(chan struct{})
+	job, err := runner.Submit("products", NewJSONSource(strings.NewReader(documents(25, 12))), Options{BatchSize: 10, Concurrency: 2}, func() { close(released) })
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if job.State != JobQueued || job.ID == "" {
+		t.Errorf("Submit() = %+v, expected a queued job", job)
+	}
+
+	job = waitJob(t, runner, job.ID)
+	if job.State != JobSucceeded || job.Documents != 15 || job.Batches != 3 || !job.Committed {
+		t.Errorf("job = %+v, expected 15 documents in 3 batches, succeeded", job)
+	}
+	if job.DocumentsFailed != 10 || job.StartedAt == nil || job.FinishedAt == nil {
+		t.Errorf("job = %+v, expected 10 failed documents and its start and finish times", job)
+	}
+	if len(solr.ids) != 15 {
+		t.Errorf("indexed %d documents, expected 15", len(solr.ids))
+	}
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Errorf("the source of the job was not released")
+	}
+
+	jobs, err := runner.List()
+	if err != nil || len(jobs) != 1 || jobs[0].ID != job.ID {
+		t.Errorf("List() = %+v, %v, expected the job", jobs, err)
+	}
+	if _, err := runner.Get("missing"); !failure.Is(err, errors.ErrNotFound) {
+		t.Errorf("Get() error = %v, expected %s", err, errors.ErrNotFound)
+	}
+}
+
+func TestJobRunnerCancel(t *testing.T) {
+	runner, started, unblock := newBlockingRunner(t, 1)
+	defer close(unblock)
+
+	running, err := runner.Submit("products", NewJSONSource(strings.NewReader(documents(5))), Options{BatchSize: 1, Concurrency: 1}, func() {})
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	<-started
+
+	queued, err := runner.Submit("products", NewJSONSource(strings.NewReader(documents(5))), Options{BatchSize: 1, Concurrency: 1}, func() {})
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+
+	// The queue holds a single job
+	if _, err := runner.Submit("products", NewJSONSource(strings.NewReader(documents(5))), Options{BatchSize: 1, Concurrency: 1}, func() {}); !failure.Is(err, errors.ErrUnavailable) {
+		t.Errorf("Submit() error = %v, expected %s", err, errors.ErrUnavailable)
+	}
+
+	// A queued job is cancelled right away
+	job, err := runner.Cancel(queued.ID)
+	if err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if job.State != JobCancelled {
+		t.Errorf("Cancel() = %+v, expected a cancelled job", job)
+	}
+
+	// A running job is cancelled once its batch is interrupted
+	if _, err := runner.Cancel(running.ID); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	job = waitJob(t, runner, running.ID)
+	if job.State != JobCancelled || job.Committed {
+		t.Errorf("job = %+v, expected a cancelled job", job)
+	}
+
+	if _, err := runner.Cancel(running.ID); !failure.Is(err, errors.ErrConflict) {
+		t.Errorf("Cancel() error = %v, expected %s", err, errors.ErrConflict)
+	}
+	if _, err := runner.Cancel("missing"); !failure.Is(err, errors.ErrNotFound) {
+		t.Errorf("Cancel() error = %v, expected %s", err, errors.ErrNotFound)
+	}
+}
+
+func TestMemoryJobStoreRetention(t *testing.T) {
+	store := NewMemoryJobStore(time.Hour)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	finished := now.Add(-2 * time.Hour)
+	jobs := []Job{
+		{ID: "old", State: JobSucceeded, CreatedAt: finished, FinishedAt: &finished},
+		{ID: "running", State: JobRunning, CreatedAt: finished.Add(time.Minute)},
+		{ID: "recent", State: JobFailed, CreatedAt: now, FinishedAt: &now},
+	}
+	for _, job := range jobs {
+		if err := store.Save(job); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	if _, err := store.Get("old"); !failure.Is(err, errors.ErrNotFound) {
+		t.Errorf("Get() error = %v, expected %s", err, errors.ErrNotFound)
+	}
+	listed, err := store.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(listed) != 2 || listed[0].ID != "recent" || listed[1].ID != "running" {
+		t.Errorf("List() = %+v, expected the recent and running jobs, most recent first", listed)
+	}
+}
@@ -0,0 +1,33 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// jsonlSource reads one document per line
+type jsonlSource struct {
+	decoder *json.Decoder
+}
+
+// NewJSONLSource creates a Source reading JSON Lines, one document per line, from r
+func NewJSONLSource(r io.Reader) Source {
+	return &jsonlSource{decoder: json.NewDecoder(bufio.NewReader(r))}
+}
+
+// Next implements Source
+func (s *jsonlSource) Next() (Document, error) {
+	var doc Document
+	if err := s.decoder.Decode(&doc); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("invalid document: null")
+	}
+	return doc, nil
+}
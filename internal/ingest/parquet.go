@@ -0,0 +1,42 @@
+package ingest
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetSource reads a document per row of a Parquet file
+type parquetSource struct {
+	reader *parquet.Reader
+}
+
+// NewParquetSource creates a Source reading the rows of the Parquet file of the given size as documents,
+// with a field per column. Null values are left out of the documents.
+func NewParquetSource(r io.ReaderAt, size int64) (Source, error) {
+	file, err := parquet.OpenFile(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Parquet file: %w", err)
+	}
+	return &parquetSource{reader: parquet.NewReader(file)}, nil
+}
+
+// Next implements Source
+func (s *parquetSource) Next() (Document, error) {
+	row := map[string]any{}
+	if err := s.reader.Read(&row); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("invalid Parquet row: %w", err)
+	}
+
+	doc := make(Document, len(row))
+	for field, value := range row {
+		if value != nil {
+			doc[field] = value
+		}
+	}
+	return doc, nil
+}
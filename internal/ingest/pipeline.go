@@ -0,0 +1,262 @@
+package ingest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/morikuni/failure/v2"
+	"github.com/samber/lo"
+	"github.com/takatori/skg/internal/analysis"
+	"github.com/takatori/skg/internal/errors"
+)
+
+// Operations of pipeline steps
+const (
+	// OpRename moves the value of From to To
+	OpRename = "rename"
+	// OpCopy copies the value of From to To
+	OpCopy = "copy"
+	// OpConcat joins the string values of Fields with Separator (default " ") into To
+	OpConcat = "concat"
+	// OpDrop removes Fields
+	OpDrop = "drop"
+	// OpDefault sets To to Value if it is missing
+	OpDefault = "default"
+	// OpCoerce converts the values of Fields to Type: "string", "int", "float" or "bool"
+	OpCoerce = "coerce"
+	// OpTokenize extracts the terms of the text in From into To with the extraction Profile and Units
+	OpTokenize = "tokenize"
+)
+
+// Pipeline is a declarative list of transformations applied to each document before it is indexed
+type Pipeline struct {
+	Name  string `json:"name"`
+	Steps []Step `json:"steps"`
+}
+
+// Step is a single transformation of a Pipeline; the fields it uses depend on its Op
+type Step struct {
+	Op        string   `json:"op"`
+	From      string   `json:"from,omitempty"`
+	To        string   `json:"to,omitempty"`
+	Fields    []string `json:"fields,omitempty"`
+	Separator *string  `json:"separator,omitempty"`
+	Value     any      `json:"value,omitempty"`
+	Type      string   `json:"type,omitempty"`
+	Profile   string   `json:"profile,omitempty"`
+	Units     string   `json:"units,omitempty"`
+}
+
+// Transform transforms a document before it is indexed
+type Transform func(Document) (Document, error)
+
+// Compile validates the pipeline and returns a Transform applying its steps in order.
+// Tokenize steps extract terms with the analyzer.
+func (p Pipeline) Compile(analyzer *analysis.Analyzer) (Transform, error) {
+	steps := make([]func(Document) error, len(p.Steps))
+	for i, step := range p.Steps {
+		apply, err := step.compile(analyzer)
+		if err != nil {
+			return nil, failure.Translate(
+				err,
+				errors.ErrBadRequest,
+				failure.Field(failure.Messagef("invalid step %d (%s) of pipeline %q: %s", i, step.Op, p.Name, errorMessage(err))),
+			)
+		}
+		steps[i] = apply
+	}
+
+	return func(doc Document) (Document, error) {
+		for i, apply := range steps {
+			if err := apply(doc); err != nil {
+				return nil, fmt.Errorf("step %d (%s): %w", i, p.Steps[i].Op, err)
+			}
+		}
+		return doc, nil
+	}, nil
+}
+
+// compile validates the step and returns a function applying it to a document in place
+func (s Step) compile(analyzer *analysis.Analyzer) (func(Document) error, error) {
+	switch s.Op {
+	case OpRename, OpCopy:
+		if s.From == "" || s.To == "" {
+			return nil, fmt.Errorf("from and to are required")
+		}
+		return func(doc Document) error {
+			if value, ok := doc[s.From]; ok {
+				if s.Op == OpRename {
+					delete(doc, s.From)
+				}
+				doc[s.To] = value
+			}
+			return nil
+		}, nil
+
+	case OpConcat:
+		if len(s.Fields) == 0 || s.To == "" {
+			return nil, fmt.Errorf("fields and to are required")
+		}
+		separator := " "
+		if s.Separator != nil {
+			separator = *s.Separator
+		}
+		return func(doc Document) error {
+			var parts []string
+			for _, field := range s.Fields {
+				parts = append(parts, stringValues(doc[field])...)
+			}
+			if len(parts) > 0 {
+				doc[s.To] = strings.Join(parts, separator)
+			}
+			return nil
+		}, nil
+
+	case OpDrop:
+		if len(s.Fields) == 0 {
+			return nil, fmt.Errorf("fields are required")
+		}
+		return func(doc Document) error {
+			for _, field := range s.Fields {
+				delete(doc, field)
+			}
+			return nil
+		}, nil
+
+	case OpDefault:
+		if s.To == "" || s.Value == nil {
+			return nil, fmt.Errorf("to and value are required")
+		}
+		return func(doc Document) error {
+			if doc[s.To] == nil {
+				doc[s.To] = s.Value
+			}
+			return nil
+		}, nil
+
+	case OpCoerce:
+		if len(s.Fields) == 0 {
+			return nil, fmt.Errorf("fields are required")
+		}
+		convert, ok := coercions[s.Type]
+		if !ok {
+			return nil, fmt.Errorf("unsupported type: %q", s.Type)
+		}
+		return func(doc Document) error {
+			for _, field := range s.Fields {
+				value, ok := doc[field]
+				if !ok || value == nil {
+					continue
+				}
+				coerced, err := coerce(value, convert)
+				if err != nil {
+					return fmt.Errorf("field %q: %w", field, err)
+				}
+				doc[field] = coerced
+			}
+			return nil
+		}, nil
+
+	case OpTokenize:
+		if s.From == "" || s.To == "" {
+			return nil, fmt.Errorf("from and to are required")
+		}
+		extractor, err := analyzer.Extractor(s.Profile, analysis.ExtractOptions{Units: analysis.Units(s.Units)})
+		if err != nil {
+			return nil, err
+		}
+		return func(doc Document) error {
+			text := strings.Join(stringValues(doc[s.From]), "\n")
+			if text != "" {
+				doc[s.To] = lo.Uniq(extractor.Extract(text))
+			}
+			return nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown operation")
+}
+
+// stringValues returns the string values of a single or multi-valued field
+func stringValues(value any) []string {
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []any:
+		var values []string
+		for _, item := range v {
+			values = append(values, stringValues(item)...)
+		}
+		return values
+	case nil:
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+	return nil
+}
+
+// coercions convert a single value to each type of coerce steps
+var coercions = map[string]func(any) (any, error){
+	"string": func(value any) (any, error) {
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		return fmt.Sprint(value), nil
+	},
+	"int": func(value any) (any, error) {
+		switch v := value.(type) {
+		case string:
+			return strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		case float64:
+			if v != math.Trunc(v) {
+				return nil, fmt.Errorf("%v is not an integer", v)
+			}
+			return int64(v), nil
+		case int64, int32, int:
+			return v, nil
+		}
+		return nil, fmt.Errorf("cannot convert %T to int", value)
+	},
+	"float": func(value any) (any, error) {
+		switch v := value.(type) {
+		case string:
+			return strconv.ParseFloat(strings.TrimSpace(v), 64)
+		case float64, float32:
+			return v, nil
+		case int64:
+			return float64(v), nil
+		case int32:
+			return float64(v), nil
+		}
+		return nil, fmt.Errorf("cannot convert %T to float", value)
+	},
+	"bool": func(value any) (any, error) {
+		switch v := value.(type) {
+		case string:
+			return strconv.ParseBool(strings.TrimSpace(v))
+		case bool:
+			return v, nil
+		}
+		return nil, fmt.Errorf("cannot convert %T to bool", value)
+	},
+}
+
+// coerce converts a single value, or each value of a multi-valued field
+func coerce(value any, convert func(any) (any, error)) (any, error) {
+	values, ok := value.([]any)
+	if !ok {
+		return convert(value)
+	}
+	coerced := make([]any, len(values))
+	for i, v := range values {
+		c, err := convert(v)
+		if err != nil {
+			return nil, err
+		}
+		coerced[i] = c
+	}
+	return coerced, nil
+}
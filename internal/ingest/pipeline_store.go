@@ -0,0 +1,158 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal/errors"
+)
+
+// PipelineStore holds named pipelines, persisting them to a JSON file if it has a path
+type PipelineStore struct {
+	mu        sync.RWMutex
+	path      string
+	pipelines map[string]Pipeline
+}
+
+// NewPipelineStore creates a PipelineStore with the pipelines of the JSON file at the path, if it exists.
+// An empty path keeps the pipelines in memory only.
+func NewPipelineStore(path string) (*PipelineStore, error) {
+	store := &PipelineStore{
+		path:      path,
+		pipelines: map[string]Pipeline{},
+	}
+	if path == "" {
+		return store, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ingest pipelines: %w", err)
+	}
+	var pipelines []Pipeline
+	if err := json.Unmarshal(b, &pipelines); err != nil {
+		return nil, fmt.Errorf("failed to parse ingest pipelines: %w", err)
+	}
+	for i, p := range pipelines {
+		if p.Name == "" {
+			return nil, fmt.Errorf("ingest pipeline %d has no name", i)
+		}
+		store.pipelines[p.Name] = p
+	}
+	return store, nil
+}
+
+// Get returns the pipeline with the name
+func (s *PipelineStore) Get(name string) (Pipeline, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.pipelines[name]
+	if !ok {
+		return Pipeline{}, failure.New(
+			errors.ErrNotFound,
+			failure.Field(failure.Messagef("ingest pipeline %q not found", name)),
+		)
+	}
+	return p, nil
+}
+
+// List returns every pipeline sorted by name
+func (s *PipelineStore) List() []Pipeline {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sorted()
+}
+
+// Put adds the pipeline or replaces the pipeline with the same name
+func (s *PipelineStore) Put(p Pipeline) error {
+	if p.Name == "" {
+		return failure.New(errors.ErrBadRequest, failure.Field(failure.Message("ingest pipeline name is required")))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, existed := s.pipelines[p.Name]
+	s.pipelines[p.Name] = p
+	if err := s.save(); err != nil {
+		if existed {
+			s.pipelines[p.Name] = previous
+		} else {
+			delete(s.pipelines, p.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes the pipeline with the name
+func (s *PipelineStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.pipelines[name]
+	if !ok {
+		return failure.New(
+			errors.ErrNotFound,
+			failure.Field(failure.Messagef("ingest pipeline %q not found", name)),
+		)
+	}
+	delete(s.pipelines, name)
+	if err := s.save(); err != nil {
+		s.pipelines[name] = previous
+		return err
+	}
+	return nil
+}
+
+// save writes the pipelines to the file of the store, replacing it atomically; s.mu must be held
+func (s *PipelineStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(s.sorted(), "", "  ")
+	if err != nil {
+		return failure.Translate(err, errors.ErrInternal, failure.Field(failure.Message("failed to encode ingest pipelines")))
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return failure.Translate(err, errors.ErrInternal, failure.Field(failure.Message("failed to save ingest pipelines")))
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return failure.Translate(err, errors.ErrInternal, failure.Field(failure.Message("failed to save ingest pipelines")))
+	}
+	if err := tmp.Close(); err != nil {
+		return failure.Translate(err, errors.ErrInternal, failure.Field(failure.Message("failed to save ingest pipelines")))
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return failure.Translate(err, errors.ErrInternal, failure.Field(failure.Message("failed to save ingest pipelines")))
+	}
+	return nil
+}
+
+// sorted returns the pipelines sorted by name; s.mu must be held
+func (s *PipelineStore) sorted() []Pipeline {
+	pipelines := make([]Pipeline, 0, len(s.pipelines))
+	for _, p := range s.pipelines {
+		pipelines = append(pipelines, p)
+	}
+	slices.SortFunc(pipelines, func(a, b Pipeline) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return pipelines
+}
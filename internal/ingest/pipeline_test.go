@@ -0,0 +1,199 @@
+package ingest
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/analysis"
+	"github.com/takatori/skg/internal/errors"
+)
+
+// newTestAnalyzer returns an analyzer with the built-in profiles
+func newTestAnalyzer(t *testing.T) *analysis.Analyzer {
+	t.Helper()
+	analyzer, err := analysis.NewAnalyzer(&internal.Config{})
+	if err != nil {
+		t.Fatalf("NewAnalyzer() error = %v", err)
+	}
+	return analyzer
+}
+
+// parsePipeline decodes a pipeline from its JSON representation
+func parsePipeline(t *testing.T, spec string) Pipeline {
+	t.Helper()
+	var p Pipeline
+	if err := json.Unmarshal([]byte(spec), &p); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	return p
+}
+
+func TestPipelineCompile(t *testing.T) {
+	analyzer := newTestAnalyzer(t)
+
+	tests := []struct {
+		name     string
+		steps    string
+		doc      Document
+		expected Document
+	}{
+		{
+			name:     "rename",
+			steps:    `[{"op":"rename","from":"Title","to":"title"}]`,
+			doc:      Document{"Title": "a", "id": "1"},
+			expected: Document{"title": "a", "id": "1"},
+		},
+		{
+			name:     "rename missing",
+			steps:    `[{"op":"rename","from":"Title","to":"title"}]`,
+			doc:      Document{"id": "1"},
+			expected: Document{"id": "1"},
+		},
+		{
+			name:     "copy",
+			steps:    `[{"op":"copy","from":"title","to":"title_ja"}]`,
+			doc:      Document{"title": "a"},
+			expected: Document{"title": "a", "title_ja": "a"},
+		},
+		{
+			name:     "concat",
+			steps:    `[{"op":"concat","fields":["title","tags","body"],"to":"text","separator":"\n"}]`,
+			doc:      Document{"title": "a", "tags": []any{"b", "c"}},
+			expected: Document{"title": "a", "tags": []any{"b", "c"}, "text": "a\nb\nc"},
+		},
+		{
+			name:     "drop",
+			steps:    `[{"op":"drop","fields":["internal","missing"]}]`,
+			doc:      Document{"id": "1", "internal": true},
+			expected: Document{"id": "1"},
+		},
+		{
+			name:     "default",
+			steps:    `[{"op":"default","to":"lang","value":"ja"},{"op":"default","to":"stock","value":0}]`,
+			doc:      Document{"stock": 3.0},
+			expected: Document{"lang": "ja", "stock": 3.0},
+		},
+		{
+			name:     "coerce",
+			steps:    `[{"op":"coerce","fields":["price","sizes"],"type":"int"},{"op":"coerce","fields":["rating"],"type":"float"},{"op":"coerce","fields":["sale"],"type":"bool"},{"op":"coerce","fields":["code"],"type":"string"}]`,
+			doc:      Document{"price": " 1200", "sizes": []any{"1", 2.0}, "rating": "4.5", "sale": "true", "code": 42.0},
+			expected: Document{"price": int64(1200), "sizes": []any{int64(1), int64(2)}, "rating": 4.5, "sale": true, "code": "42"},
+		},
+		{
+			name:     "tokenize",
+			steps:    `[{"op":"tokenize","from":"title","to":"terms"}]`,
+			doc:      Document{"title": "機械学習と機械翻訳"},
+			expected: Document{"title": "機械学習と機械翻訳", "terms": []string{"機械", "学習", "翻訳"}},
+		},
+		{
+			name:     "tokenize compounds",
+			steps:    `[{"op":"tokenize","from":"title","to":"terms","units":"compounds"}]`,
+			doc:      Document{"title": "機械学習と機械翻訳"},
+			expected: Document{"title": "機械学習と機械翻訳", "terms": []string{"機械学習", "機械翻訳"}},
+		},
+		{
+			name:     "steps in order",
+			steps:    `[{"op":"rename","from":"name","to":"title"},{"op":"copy","from":"title","to":"text"},{"op":"drop","fields":["title"]}]`,
+			doc:      Document{"name": "a"},
+			expected: Document{"text": "a"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := parsePipeline(t, `{"name":"test","steps":`+test.steps+`}`)
+			transform, err := p.Compile(analyzer)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			doc, err := transform(test.doc)
+			if err != nil {
+				t.Fatalf("transform() error = %v", err)
+			}
+			if !reflect.DeepEqual(doc, test.expected) {
+				t.Errorf("transform() = %#v, expected %#v", doc, test.expected)
+			}
+		})
+	}
+}
+
+func TestPipelineCompileInvalid(t *testing.T) {
+	analyzer := newTestAnalyzer(t)
+
+	tests := []string{
+		`[{"op":"upper","fields":["title"]}]`,
+		`[{"op":"rename","from":"title"}]`,
+		`[{"op":"concat","to":"text"}]`,
+		`[{"op":"drop"}]`,
+		`[{"op":"default","to":"lang"}]`,
+		`[{"op":"coerce","fields":["price"],"type":"date"}]`,
+		`[{"op":"tokenize","from":"title","to":"terms","profile":"missing"}]`,
+	}
+
+	for _, steps := range tests {
+		p := parsePipeline(t, `{"name":"test","steps":`+steps+`}`)
+		if _, err := p.Compile(analyzer); !failure.Is(err, errors.ErrBadRequest) {
+			t.Errorf("Compile(%s) error = %v, expected %s", steps, err, errors.ErrBadRequest)
+		}
+	}
+}
+
+func TestPipelineCoerceError(t *testing.T) {
+	p := parsePipeline(t, `{"name":"test","steps":[{"op":"coerce","fields":["price"],"type":"int"}]}`)
+	transform, err := p.Compile(newTestAnalyzer(t))
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	for _, value := range []any{"abc", 1.5, true} {
+		if _, err := transform(Document{"price": value}); err == nil {
+			t.Errorf("transform(%v) expected an error", value)
+		}
+	}
+}
+
+func TestPipelineStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pipelines.json")
+	store, err := NewPipelineStore(path)
+	if err != nil {
+		t.Fatalf("NewPipelineStore() error = %v", err)
+	}
+
+	products := Pipeline{Name: "products", Steps: []Step{{Op: OpDrop, Fields: []string{"internal"}}}}
+	articles := Pipeline{Name: "articles", Steps: []Step{{Op: OpCopy, From: "title", To: "text"}}}
+	for _, p := range []Pipeline{products, articles} {
+		if err := store.Put(p); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+	if err := store.Put(Pipeline{}); !failure.Is(err, errors.ErrBadRequest) {
+		t.Errorf("Put() error = %v, expected %s", err, errors.ErrBadRequest)
+	}
+
+	// The pipelines are persisted across stores
+	reopened, err := NewPipelineStore(path)
+	if err != nil {
+		t.Fatalf("NewPipelineStore() error = %v", err)
+	}
+	expected := []Pipeline{articles, products}
+	if pipelines := reopened.List(); !reflect.DeepEqual(pipelines, expected) {
+		t.Errorf("List() = %+v, expected %+v", pipelines, expected)
+	}
+
+	if err := reopened.Delete("articles"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := reopened.Get("articles"); !failure.Is(err, errors.ErrNotFound) {
+		t.Errorf("Get() error = %v, expected %s", err, errors.ErrNotFound)
+	}
+	if err := reopened.Delete("articles"); !failure.Is(err, errors.ErrNotFound) {
+		t.Errorf("Delete() error = %v, expected %s", err, errors.ErrNotFound)
+	}
+	if p, err := reopened.Get("products"); err != nil || !reflect.DeepEqual(p, products) {
+		t.Errorf("Get() = %+v, %v, expected %+v", p, err, products)
+	}
+}
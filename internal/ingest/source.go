@@ -0,0 +1,102 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Document is a document to index, as a map from field names to values
+type Document = map[string]any
+
+// Source reads documents one at a time.
+// Next returns io.EOF once every document has been read.
+type Source interface {
+	Next() (Document, error)
+}
+
+// jsonSource reads the documents of a JSON array, or a single JSON object, without loading them all
+type jsonSource struct {
+	reader  *bufio.Reader
+	decoder *json.Decoder
+	single  bool // whether the input is a single object rather than an array
+	done    bool
+}
+
+// NewJSONSource creates a Source reading a JSON array of documents, or a single document, from r
+func NewJSONSource(r io.Reader) Source {
+	return &jsonSource{reader: bufio.NewReader(r)}
+}
+
+// Next implements Source
+func (s *jsonSource) Next() (Document, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+	if s.decoder == nil {
+		if err := s.start(); err != nil {
+			s.done = true
+			return nil, err
+		}
+	}
+
+	if s.single {
+		s.done = true
+		return s.decode()
+	}
+	if !s.decoder.More() {
+		s.done = true
+		// Consume the closing bracket so that a truncated array is reported
+		if _, err := s.decoder.Token(); err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %w", err)
+		}
+		return nil, io.EOF
+	}
+	return s.decode()
+}
+
+// start detects whether the input is an array or a single object and reads the opening bracket of an array
+func (s *jsonSource) start() error {
+	first, err := s.firstByte()
+	if err != nil {
+		return err
+	}
+	s.decoder = json.NewDecoder(s.reader)
+	switch first {
+	case '{':
+		s.single = true
+		return nil
+	case '[':
+		_, err := s.decoder.Token()
+		return err
+	}
+	return fmt.Errorf("expected a JSON array or object, got %q", first)
+}
+
+// firstByte returns the first byte of the input that is not white space, without consuming it
+func (s *jsonSource) firstByte() (byte, error) {
+	for {
+		b, err := s.reader.ReadByte()
+		if err == io.EOF {
+			return 0, fmt.Errorf("empty input")
+		}
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, s.reader.UnreadByte()
+	}
+}
+
+// decode decodes the next document
+func (s *jsonSource) decode() (Document, error) {
+	var doc Document
+	if err := s.decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+	return doc, nil
+}
@@ -0,0 +1,84 @@
+package ingest
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readAll reads every document of the source
+func readAll(source Source) ([]Document, error) {
+	var docs []Document
+	for {
+		doc, err := source.Next()
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return docs, err
+		}
+		docs = append(docs, doc)
+	}
+}
+
+func TestJSONSource(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		expected   []Document
+		expectedOk bool
+	}{
+		{
+			name:       "array",
+			input:      ` [{"id":"1","title":"機械学習"}, {"id":"2"}]`,
+			expected:   []Document{{"id": "1", "title": "機械学習"}, {"id": "2"}},
+			expectedOk: true,
+		},
+		{
+			name:       "single object",
+			input:      "\n{\"id\":\"1\"}",
+			expected:   []Document{{"id": "1"}},
+			expectedOk: true,
+		},
+		{
+			name:       "empty array",
+			input:      `[]`,
+			expectedOk: true,
+		},
+		{
+			name:       "truncated array",
+			input:      `[{"id":"1"},`,
+			expected:   []Document{{"id": "1"}},
+			expectedOk: false,
+		},
+		{
+			name:       "not a document",
+			input:      `[{"id":"1"}, 2]`,
+			expected:   []Document{{"id": "1"}},
+			expectedOk: false,
+		},
+		{
+			name:       "scalar",
+			input:      `"id"`,
+			expectedOk: false,
+		},
+		{
+			name:       "empty",
+			input:      ``,
+			expectedOk: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			docs, err := readAll(NewJSONSource(strings.NewReader(test.input)))
+			if (err == nil) != test.expectedOk {
+				t.Errorf("Next() error = %v, expected ok %v", err, test.expectedOk)
+			}
+			if !reflect.DeepEqual(docs, test.expected) {
+				t.Errorf("Next() = %v, expected %v", docs, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/takatori/skg/internal/analysis"
+)
+
+// AnalysisHandler handles requests for managing the text analyzer
+type AnalysisHandler struct {
+	analyzer *analysis.Analyzer
+}
+
+// NewAnalysisHandler creates a new AnalysisHandler for the given text analyzer
+func NewAnalysisHandler(analyzer *analysis.Analyzer) *AnalysisHandler {
+	return &AnalysisHandler{
+		analyzer: analyzer,
+	}
+}
+
+// ReloadEndpoint returns an Echo handler function that reloads the user dictionary of the analyzer,
+// so that dictionary updates take effect without a restart
+func (h *AnalysisHandler) ReloadEndpoint() func(echo.Context) error {
+	return func(c echo.Context) error {
+		if err := h.analyzer.Reload(); err != nil {
+			return fmt.Errorf("failed to reload analyzer: %w", err)
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "analyzer reloaded"})
+	}
+}
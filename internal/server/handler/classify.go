@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
+	"github.com/takatori/skg/internal/analysis"
+	"github.com/takatori/skg/internal/skg"
+	"github.com/takatori/skg/internal/skg/solr"
+)
+
+// defaultLabelLimit is the number of labels considered when they are taken from a label field
+const defaultLabelLimit = 10
+
+// ClassifyParams defines the parameters for the classification API.
+// Either Labels or LabelField must be given: with Labels only, each label is queried on the text field;
+// with LabelField only, the field's values most related to the foreground are used as labels.
+type ClassifyParams struct {
+	Document   string   `json:"document" validate:"required"`
+	Collection string   `json:"collection" validate:"required"`
+	Labels     []string `json:"labels"`     // candidate labels
+	LabelField string   `json:"labelField"` // the field holding labels, e.g. "category"
+	LabelLimit *int     `json:"labelLimit"` // maximum number of labels taken from LabelField; defaults to 10
+	Field      string   `json:"field"`      // defaults to the collection's text field for the document's language
+	ExtractionParams
+	DocumentSetParams
+}
+
+// LabelScore represents a candidate label scored against a document
+type LabelScore struct {
+	Label            string        `json:"label"`
+	Score            float64       `json:"score"`            // mean relatedness of the document's terms within the label
+	LabelRelatedness float64       `json:"labelRelatedness"` // relatedness of the label itself
+	Count            int64         `json:"count"`
+	Terms            []RelatedTerm `json:"terms"`
+}
+
+// Classify returns an Echo handler function that scores a document against candidate labels
+// by the relatedness of the document's terms within each label
+func (h *RelatedTermsHandler) Classify() func(echo.Context) error {
+	return func(c echo.Context) error {
+		var params ClassifyParams
+		if err := c.Bind(&params); err != nil {
+			return badRequest(err)
+		}
+		if err := validateClassifyParams(params); err != nil {
+			return badRequest(err)
+		}
+
+		collection := resolveCollection(params.Collection)
+		language := analysis.ResolveLanguage(params.Language, params.Document)
+		field := resolveLanguageField(h.config, collection, language, params.Field)
+		labelField := params.LabelField
+		if labelField == "" {
+			labelField = field
+		}
+		if err := h.schema.ValidateFields(c.Request().Context(), collection, field, labelField); err != nil {
+			return err
+		}
+
+		terms, indexed, err := h.extractTerms(c.Request().Context(), collection, field, params.ExtractionParams, params.Document)
+		if err != nil {
+			return err
+		}
+		if len(terms) == 0 {
+			return badRequest(fmt.Errorf("no terms could be extracted from the document"))
+		}
+
+		queries := buildClassificationQueries(
+			terms,
+			indexed,
+			field,
+			params.Labels,
+			labelField,
+			lo.FromPtrOr(params.LabelLimit, defaultLabelLimit),
+		)
+
+		skgInstance := solr.NewSolrSemanticKnowledgeGraphWithClient(h.config, h.httpClient)
+		result, err := skgInstance.Traverse(c.Request().Context(), queries, collection, params.TraverseOptions())
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, scoreLabels(result, len(terms)))
+	}
+}
+
+// validateClassifyParams validates the parameters of the classification API
+func validateClassifyParams(params ClassifyParams) error {
+	if params.Document == "" {
+		return fmt.Errorf("document is required")
+	}
+	if len(params.Labels) == 0 && params.LabelField == "" {
+		return fmt.Errorf("either labels or labelField is required")
+	}
+	if params.LabelLimit != nil && *params.LabelLimit < 1 {
+		return fmt.Errorf("labelLimit must be positive: %d", *params.LabelLimit)
+	}
+	return nil
+}
+
+// buildClassificationQueries constructs a 2-level graph: the candidate labels, either given or
+// faceted from the label field, and the document's terms within each label, queried as-is if they are indexed terms
+func buildClassificationQueries(terms []string, indexed bool, field string, labels []string, labelField string, labelLimit int) [][]skg.Query {
+	labelQuery := skg.Query{
+		Name:   "labels",
+		Field:  labelField,
+		Values: labels,
+	}
+	if len(labels) == 0 {
+		labelQuery.MinOccurrence = lo.ToPtr(1)
+		labelQuery.Limit = lo.ToPtr(labelLimit)
+	}
+
+	return [][]skg.Query{
+		{labelQuery},
+		{
+			{
+				Name:   "terms",
+				Field:  field,
+				Values: terms,
+				Terms:  indexed,
+				Sort:   skg.SortByRelatedness,
+			},
+		},
+	}
+}
+
+// scoreLabels aggregates the relatedness of the document's terms within each label into a score,
+// returning labels from the highest to the lowest score.
+// The score is the mean over all of the document's terms, so terms that do not occur count as 0.
+func scoreLabels(result []skg.Traversal, termCount int) []LabelScore {
+	scores := []LabelScore{}
+
+	for _, labels := range result {
+		for _, label := range labels.Values {
+			score := LabelScore{
+				Label:            label.Key,
+				LabelRelatedness: label.Relatedness,
+				Count:            label.Count,
+				Terms:            []RelatedTerm{},
+			}
+
+			var sum float64
+			for _, terms := range label.Traversals {
+				for _, term := range terms.Values {
+					sum += term.Relatedness
+					score.Terms = append(score.Terms, newRelatedTerm(term))
+				}
+			}
+			if termCount > 0 {
+				score.Score = sum / float64(termCount)
+			}
+			scores = append(scores, score)
+		}
+	}
+
+	slices.SortStableFunc(scores, func(a, b LabelScore) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Label, b.Label)
+	})
+	return scores
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/takatori/skg/internal/skg"
+)
+
+func TestBuildClassificationQueries(t *testing.T) {
+	given := buildClassificationQueries([]string{"機械", "学習"}, false, "text", []string{"AI", "料理"}, "category", 10)
+	if q := given[0][0]; q.Field != "category" || len(q.Values) != 2 || q.Limit != nil {
+		t.Errorf("labels query = %+v, expected a query facet per label", q)
+	}
+
+	faceted := buildClassificationQueries([]string{"機械", "学習"}, false, "text", nil, "category", 3)
+	if q := faceted[0][0]; q.Field != "category" || len(q.Values) != 0 || *q.Limit != 3 {
+		t.Errorf("labels query = %+v, expected a terms facet limited to 3", q)
+	}
+	if q := faceted[1][0]; q.Field != "text" || len(q.Values) != 2 {
+		t.Errorf("terms query = %+v, expected a query facet per term", q)
+	}
+}
+
+func TestScoreLabels(t *testing.T) {
+	result := []skg.Traversal{{
+		Name: "labels",
+		Values: []skg.Node{
+			{
+				Key:         "cooking",
+				Relatedness: 0.1,
+				Traversals: []skg.Traversal{{
+					Name:   "terms",
+					Values: []skg.Node{{Key: "機械", Relatedness: -0.2}, {Key: "学習", Relatedness: 0.1}},
+				}},
+			},
+			{
+				Key:         "AI",
+				Relatedness: 0.3,
+				Traversals: []skg.Traversal{{
+					Name:   "terms",
+					Values: []skg.Node{{Key: "機械", Relatedness: 0.8}, {Key: "学習", Relatedness: 0.6}},
+				}},
+			},
+			{Key: "empty"},
+		},
+	}}
+
+	scores := scoreLabels(result, 2)
+	if len(scores) != 3 {
+		t.Fatalf("Expected 3 labels, got %d", len(scores))
+	}
+
+	expected := []struct {
+		label string
+		score float64
+	}{
+		{"AI", 0.7},
+		{"empty", 0.0},
+		{"cooking", -0.05},
+	}
+	for i, e := range expected {
+		if scores[i].Label != e.label || !almostEqual(scores[i].Score, e.score) {
+			t.Errorf("scores[%d] = %s/%f, expected %s/%f", i, scores[i].Label, scores[i].Score, e.label, e.score)
+		}
+	}
+}
+
+func almostEqual(a, b float64) bool {
+	diff := a - b
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= 0.00001
+}
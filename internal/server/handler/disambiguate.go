@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
+	"github.com/takatori/skg/internal/skg"
+	"github.com/takatori/skg/internal/skg/solr"
+)
+
+const (
+	defaultSenseLimit     = 5
+	defaultSenseTermLimit = 10
+)
+
+// DisambiguateParams defines the parameters for the disambiguation API
+type DisambiguateParams struct {
+	Keyword      string `json:"keyword" validate:"required"`
+	Collection   string `json:"collection" validate:"required"`
+	ContextField string `json:"contextField" validate:"required"` // the field whose values distinguish senses, e.g. "category"
+	Field        string `json:"field"`                            // defaults to the collection's text field
+	SenseLimit   *int   `json:"senseLimit"`                       // maximum number of senses; defaults to 5
+	TermLimit    *int   `json:"termLimit"`                        // maximum number of related terms per sense; defaults to 10
+	DocumentSetParams
+}
+
+// Sense represents one meaning of the keyword, identified by a value of the context field
+type Sense struct {
+	Context              string        `json:"context"`
+	Relatedness          float64       `json:"relatedness"`
+	Count                int64         `json:"count"`
+	ForegroundPopularity float64       `json:"foregroundPopularity"`
+	BackgroundPopularity float64       `json:"backgroundPopularity"`
+	Terms                []RelatedTerm `json:"terms"`
+}
+
+// DisambiguationResult is the response of the disambiguation API
+type DisambiguationResult struct {
+	Keyword string  `json:"keyword"`
+	Senses  []Sense `json:"senses"`
+}
+
+// Disambiguate returns an Echo handler function that splits a keyword into senses by the values
+// of a context field, along with the terms most related to the keyword within each sense
+func (h *RelatedTermsHandler) Disambiguate() func(echo.Context) error {
+	return func(c echo.Context) error {
+		var params DisambiguateParams
+		if err := c.Bind(&params); err != nil {
+			return badRequest(err)
+		}
+		if err := validateDisambiguateParams(params); err != nil {
+			return badRequest(err)
+		}
+
+		collection := resolveCollection(params.Collection)
+		field := resolveField(h.config, collection, params.Field)
+		if err := h.schema.ValidateFields(c.Request().Context(), collection, field, params.ContextField); err != nil {
+			return err
+		}
+
+		queries := buildDisambiguationQueries(
+			params.Keyword,
+			field,
+			params.ContextField,
+			lo.FromPtrOr(params.SenseLimit, defaultSenseLimit),
+			lo.FromPtrOr(params.TermLimit, defaultSenseTermLimit),
+		)
+
+		skgInstance := solr.NewSolrSemanticKnowledgeGraphWithClient(h.config, h.httpClient)
+		result, err := skgInstance.Traverse(c.Request().Context(), queries, collection, params.TraverseOptions())
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, DisambiguationResult{
+			Keyword: params.Keyword,
+			Senses:  extractSenses(result),
+		})
+	}
+}
+
+// validateDisambiguateParams validates the parameters of the disambiguation API
+func validateDisambiguateParams(params DisambiguateParams) error {
+	if params.Keyword == "" {
+		return fmt.Errorf("keyword is required")
+	}
+	if params.ContextField == "" {
+		return fmt.Errorf("contextField is required")
+	}
+	if params.SenseLimit != nil && *params.SenseLimit < 1 {
+		return fmt.Errorf("senseLimit must be positive: %d", *params.SenseLimit)
+	}
+	if params.TermLimit != nil && *params.TermLimit < 1 {
+		return fmt.Errorf("termLimit must be positive: %d", *params.TermLimit)
+	}
+	return nil
+}
+
+// buildDisambiguationQueries constructs a 3-level graph: the keyword, the values of the context field
+// related to the keyword, and the terms related to the keyword within each context value
+func buildDisambiguationQueries(keyword, field, contextField string, senseLimit, termLimit int) [][]skg.Query {
+	return [][]skg.Query{
+		{
+			{
+				Field:  field,
+				Values: []string{keyword},
+			},
+		},
+		{
+			{
+				Name:          "context",
+				Field:         contextField,
+				MinOccurrence: lo.ToPtr(1),
+				Limit:         lo.ToPtr(senseLimit),
+			},
+		},
+		{
+			{
+				Name:          "terms",
+				Field:         field,
+				MinOccurrence: lo.ToPtr(2),
+				Limit:         lo.ToPtr(termLimit),
+			},
+		},
+	}
+}
+
+// extractSenses processes the SKG result of buildDisambiguationQueries into senses
+func extractSenses(result []skg.Traversal) []Sense {
+	senses := []Sense{}
+
+	for _, item := range result {
+		// Skip if there are no values
+		if len(item.Values) == 0 {
+			continue
+		}
+
+		for _, contexts := range item.Values[0].Traversals {
+			for _, context := range contexts.Values {
+				sense := Sense{
+					Context:              context.Key,
+					Relatedness:          context.Relatedness,
+					Count:                context.Count,
+					ForegroundPopularity: context.ForegroundPopularity,
+					BackgroundPopularity: context.BackgroundPopularity,
+					Terms:                []RelatedTerm{},
+				}
+				for _, terms := range context.Traversals {
+					for _, term := range terms.Values {
+						sense.Terms = append(sense.Terms, newRelatedTerm(term))
+					}
+				}
+				senses = append(senses, sense)
+			}
+		}
+	}
+
+	return senses
+}
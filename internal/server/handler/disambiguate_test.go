@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/takatori/skg/internal/skg"
+)
+
+func TestExtractSenses(t *testing.T) {
+	result := []skg.Traversal{
+		{
+			Name: "f0",
+			Values: []skg.Node{{
+				Key: "java",
+				Traversals: []skg.Traversal{{
+					Name: "context",
+					Values: []skg.Node{
+						{
+							Key:         "programming",
+							Relatedness: 0.8,
+							Count:       120,
+							Traversals: []skg.Traversal{{
+								Name:   "terms",
+								Values: []skg.Node{{Key: "jvm", Relatedness: 0.9}, {Key: "class", Relatedness: 0.7}},
+							}},
+						},
+						{
+							Key:         "travel",
+							Relatedness: 0.4,
+							Count:       30,
+							Traversals: []skg.Traversal{{
+								Name:   "terms",
+								Values: []skg.Node{{Key: "indonesia", Relatedness: 0.85}},
+							}},
+						},
+						{Key: "coffee", Relatedness: 0.2},
+					},
+				}},
+			}},
+		},
+	}
+
+	senses := extractSenses(result)
+	if len(senses) != 3 {
+		t.Fatalf("Expected 3 senses, got %d", len(senses))
+	}
+
+	expected := []struct {
+		context string
+		count   int64
+		terms   []string
+	}{
+		{"programming", 120, []string{"jvm", "class"}},
+		{"travel", 30, []string{"indonesia"}},
+		{"coffee", 0, nil},
+	}
+	for i, e := range expected {
+		sense := senses[i]
+		if sense.Context != e.context || sense.Count != e.count || len(sense.Terms) != len(e.terms) {
+			t.Fatalf("sense[%d] = %+v, expected %s with %d terms", i, sense, e.context, len(e.terms))
+		}
+		for j, term := range e.terms {
+			if sense.Terms[j].Term != term {
+				t.Errorf("sense[%d] term[%d] = %s, expected %s", i, j, sense.Terms[j].Term, term)
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	stderrors "errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal/errors"
+)
+
+// ErrorResponse is the JSON envelope of every error response
+type ErrorResponse struct {
+	Error string `json:"error"` // a message for clients, e.g. Solr's error message
+	Code  string `json:"code"`  // the error code, one of the codes in internal/errors
+}
+
+// NewErrorHandler returns an Echo error handler that answers the errors returned by handlers
+// with an ErrorResponse and the HTTP status code of their error code
+func NewErrorHandler() echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		if c.Response().Committed {
+			return
+		}
+
+		status, response := errorStatus(err), ErrorResponse{Error: errorMessage(err), Code: string(errorCode(err))}
+		if status >= http.StatusInternalServerError {
+			slog.Error("request failed", "method", c.Request().Method, "path", c.Path(), "status", status, "error", err)
+		}
+
+		if c.Request().Method == http.MethodHead {
+			err = c.NoContent(status)
+		} else {
+			err = c.JSON(status, response)
+		}
+		if err != nil {
+			slog.Warn("failed to send error response", "error", err)
+		}
+	}
+}
+
+// badRequest converts an error in the request parameters into an errors.ErrBadRequest failure
+func badRequest(err error) error {
+	return failure.Translate(err, errors.ErrBadRequest, failure.Field(failure.Message(err.Error())))
+}
+
+// errorCode returns the error code of an error returned by a handler.
+// Errors of Echo, such as unknown routes, get the code of their status.
+func errorCode(err error) errors.ErrorCode {
+	if code, ok := failure.CodeOf(err).(errors.ErrorCode); ok {
+		return code
+	}
+	var httpErr *echo.HTTPError
+	if stderrors.As(err, &httpErr) {
+		if code := errors.CodeOfStatus(httpErr.Code); code != errors.ErrInternal || httpErr.Code >= http.StatusInternalServerError {
+			return code
+		}
+		// Other client errors of Echo, e.g. 405 Method Not Allowed
+		return errors.ErrBadRequest
+	}
+	return errors.ErrInternal
+}
+
+// errorStatus returns the HTTP status code for an error returned by a handler
+func errorStatus(err error) int {
+	var httpErr *echo.HTTPError
+	if failure.CodeOf(err) == nil && stderrors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+
+	switch errorCode(err) {
+	case errors.ErrBadRequest:
+		return http.StatusBadRequest
+	case errors.ErrNotFound:
+		return http.StatusNotFound
+	case errors.ErrConflict:
+		return http.StatusConflict
+	case errors.ErrUnavailable:
+		return http.StatusServiceUnavailable
+	case errors.ErrTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorMessage returns the message of the error to show to clients,
+// preferring the failure message over the full error chain
+func errorMessage(err error) string {
+	if msg := failure.MessageOf(err); msg != "" {
+		return msg.String()
+	}
+	var httpErr *echo.HTTPError
+	if stderrors.As(err, &httpErr) {
+		if msg, ok := httpErr.Message.(string); ok {
+			return msg
+		}
+	}
+	return err.Error()
+}
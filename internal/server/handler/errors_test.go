@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal/errors"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"bad request", failure.New(errors.ErrBadRequest), http.StatusBadRequest},
+		{"timeout", fmt.Errorf("failed to send post request: %w", failure.New(errors.ErrTimeout)), http.StatusGatewayTimeout},
+		{"not found", failure.New(errors.ErrNotFound), http.StatusNotFound},
+		{"conflict", failure.New(errors.ErrConflict), http.StatusConflict},
+		{"unavailable", failure.New(errors.ErrUnavailable), http.StatusServiceUnavailable},
+		{"echo", echo.ErrMethodNotAllowed, http.StatusMethodNotAllowed},
+		{"internal", failure.New(errors.ErrInternal), http.StatusInternalServerError},
+		{"plain error", stderrors.New("boom"), http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		if result := errorStatus(test.err); result != test.expected {
+			t.Errorf("errorStatus(%s) = %d, expected %d", test.name, result, test.expected)
+		}
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		err              error
+		expectedStatus   int
+		expectedResponse ErrorResponse
+	}{
+		{
+			name: "solr error",
+			err: fmt.Errorf("failed to update schema: %w", failure.New(
+				errors.ErrBadRequest,
+				failure.Field(failure.Message("undefined field title")),
+			)),
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: ErrorResponse{Error: "undefined field title", Code: "BadRequest"},
+		},
+		{
+			name:             "plain error",
+			err:              stderrors.New("boom"),
+			expectedStatus:   http.StatusInternalServerError,
+			expectedResponse: ErrorResponse{Error: "boom", Code: "Internal"},
+		},
+		{
+			name:             "unknown route",
+			err:              echo.ErrNotFound,
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: ErrorResponse{Error: "Not Found", Code: "NotFound"},
+		},
+	}
+
+	e := echo.New()
+	errorHandler := NewErrorHandler()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+			errorHandler(test.err, c)
+
+			if rec.Code != test.expectedStatus {
+				t.Errorf("status = %d, expected %d", rec.Code, test.expectedStatus)
+			}
+			var response ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to decode response %q: %v", rec.Body.String(), err)
+			}
+			if response != test.expectedResponse {
+				t.Errorf("response = %+v, expected %+v", response, test.expectedResponse)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
+	"github.com/takatori/skg/internal/skg"
+	"github.com/takatori/skg/internal/skg/solr"
+)
+
+const (
+	defaultMinRelatedness = 0.25
+	defaultMaxExpansions  = 5
+)
+
+// ExpandQueryParams defines the parameters for the query expansion API
+type ExpandQueryParams struct {
+	Query          string   `json:"query" validate:"required"`
+	Collection     string   `json:"collection" validate:"required"`
+	Field          string   `json:"field"`          // defaults to the collection's text field
+	MinRelatedness *float64 `json:"minRelatedness"` // terms less related than this are not added; defaults to 0.25
+	MaxExpansions  *int     `json:"maxExpansions"`  // maximum number of added terms; defaults to 5
+	DocumentSetParams
+}
+
+// ExpansionClause is a term added to the query, boosted by its relatedness
+type ExpansionClause struct {
+	Term        string  `json:"term"`
+	Boost       float64 `json:"boost"`
+	Relatedness float64 `json:"relatedness"`
+	Count       int64   `json:"count"`
+}
+
+// ExpandedQuery is the response of the query expansion API. The expanded query matches documents
+// matching either the original query or any added term, which is boosted by its relatedness.
+// Params can be sent to Solr as-is to run it, and JSONQuery is the same query for a JSON Request API body.
+type ExpandedQuery struct {
+	Original   string            `json:"original"`
+	Field      string            `json:"field"`
+	Query      string            `json:"query"` // the expanded query in edismax syntax
+	Params     map[string]string `json:"params"`
+	JSONQuery  map[string]any    `json:"jsonQuery"` // the expanded query in the JSON Query DSL
+	Expansions []ExpansionClause `json:"expansions"`
+}
+
+// ExpandQuery returns an Echo handler function that expands a query with the terms most related to it
+func (h *RelatedTermsHandler) ExpandQuery() func(echo.Context) error {
+	return func(c echo.Context) error {
+		var params ExpandQueryParams
+		if err := c.Bind(&params); err != nil {
+			return badRequest(err)
+		}
+		if err := validateExpandQueryParams(params); err != nil {
+			return badRequest(err)
+		}
+
+		collection := resolveCollection(params.Collection)
+		field := resolveField(h.config, collection, params.Field)
+		if err := h.schema.ValidateFields(c.Request().Context(), collection, field); err != nil {
+			return err
+		}
+
+		// Fetch extra terms since the query's own terms are usually among the most related ones
+		maxExpansions := lo.FromPtrOr(params.MaxExpansions, defaultMaxExpansions)
+		limit := maxExpansions + len(strings.Fields(params.Query))
+		queries := buildQueries(params.Query, field, limit, skg.SortByRelatedness, skg.Descending)
+
+		skgInstance := solr.NewSolrSemanticKnowledgeGraphWithClient(h.config, h.httpClient)
+		result, err := skgInstance.Traverse(c.Request().Context(), queries, collection, params.TraverseOptions())
+		if err != nil {
+			return err
+		}
+
+		expanded := buildExpandedQuery(
+			params.Query,
+			field,
+			extractRelatedTerms(result),
+			lo.FromPtrOr(params.MinRelatedness, defaultMinRelatedness),
+			maxExpansions,
+		)
+		return c.JSON(http.StatusOK, expanded)
+	}
+}
+
+// validateExpandQueryParams validates the parameters of the query expansion API
+func validateExpandQueryParams(params ExpandQueryParams) error {
+	if strings.TrimSpace(params.Query) == "" {
+		return fmt.Errorf("query is required")
+	}
+	// Negative boosts are rejected by Solr, so negatively related terms are never added
+	if params.MinRelatedness != nil && (*params.MinRelatedness < 0 || *params.MinRelatedness > 1) {
+		return fmt.Errorf("minRelatedness must be between 0 and 1: %v", *params.MinRelatedness)
+	}
+	if params.MaxExpansions != nil && *params.MaxExpansions < 0 {
+		return fmt.Errorf("maxExpansions must not be negative: %d", *params.MaxExpansions)
+	}
+	return nil
+}
+
+// buildExpandedQuery rewrites the original query into an edismax and a JSON query that also match any of up to maxExpansions
+// of the related terms whose relatedness is at least minRelatedness, each boosted by its relatedness.
+// Terms already in the original query are not added again.
+func buildExpandedQuery(original, field string, terms []RelatedTerm, minRelatedness float64, maxExpansions int) ExpandedQuery {
+	originalTerms := map[string]bool{}
+	for _, t := range strings.Fields(original) {
+		originalTerms[strings.ToLower(t)] = true
+	}
+
+	expansions := []ExpansionClause{}
+	clauses := []string{"(" + original + ")"}
+	should := []any{map[string]any{"edismax": map[string]any{"query": original, "qf": field}}}
+	for _, term := range terms {
+		if len(expansions) >= maxExpansions {
+			break
+		}
+		if term.Relatedness < minRelatedness || term.Term == "" || originalTerms[strings.ToLower(term.Term)] {
+			continue
+		}
+		originalTerms[strings.ToLower(term.Term)] = true
+
+		expansions = append(expansions, ExpansionClause{
+			Term:        term.Term,
+			Boost:       term.Relatedness,
+			Relatedness: term.Relatedness,
+			Count:       term.Count,
+		})
+		clauses = append(clauses, escapeQueryTerm(term.Term)+"^"+strconv.FormatFloat(term.Relatedness, 'f', -1, 64))
+		should = append(should, map[string]any{
+			"boost": map[string]any{
+				"b":     term.Relatedness,
+				"query": map[string]any{"field": map[string]any{"f": field, "query": term.Term}},
+			},
+		})
+	}
+
+	query := strings.Join(clauses, " OR ")
+	return ExpandedQuery{
+		Original: original,
+		Field:    field,
+		Query:    query,
+		Params: map[string]string{
+			"defType": "edismax",
+			"q":       query,
+			"qf":      field,
+		},
+		JSONQuery:  map[string]any{"bool": map[string]any{"should": should}},
+		Expansions: expansions,
+	}
+}
+
+// escapeQueryTerm escapes a term for use in an edismax query,
+// quoting terms with whitespace as phrases and escaping special characters otherwise
+func escapeQueryTerm(term string) string {
+	if strings.IndexFunc(term, unicode.IsSpace) >= 0 {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(term) + `"`
+	}
+
+	var b strings.Builder
+	for _, r := range term {
+		if strings.ContainsRune(`\+-!():^[]"{}~*?|&;/`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
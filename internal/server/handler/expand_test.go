@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildExpandedQuery(t *testing.T) {
+	terms := []RelatedTerm{
+		{Term: "java", Relatedness: 0.95, Count: 300},
+		{Term: "jvm", Relatedness: 0.9, Count: 120},
+		{Term: "c++", Relatedness: 0.6, Count: 80},
+		{Term: "spring boot", Relatedness: 0.5, Count: 40},
+		{Term: "kotlin", Relatedness: 0.45, Count: 60},
+		{Term: "coffee", Relatedness: 0.1, Count: 500},
+	}
+
+	expanded := buildExpandedQuery("Java", "text", terms, 0.25, 3)
+
+	expectedQuery := `(Java) OR jvm^0.9 OR c\+\+^0.6 OR "spring boot"^0.5`
+	if expanded.Query != expectedQuery {
+		t.Errorf("Query = %s, expected %s", expanded.Query, expectedQuery)
+	}
+	expectedParams := map[string]string{"defType": "edismax", "q": expectedQuery, "qf": "text"}
+	if !reflect.DeepEqual(expanded.Params, expectedParams) {
+		t.Errorf("Params = %v, expected %v", expanded.Params, expectedParams)
+	}
+
+	expectedJSONQuery := `{"bool":{"should":[{"edismax":{"qf":"text","query":"Java"}},` +
+		`{"boost":{"b":0.9,"query":{"field":{"f":"text","query":"jvm"}}}},` +
+		`{"boost":{"b":0.6,"query":{"field":{"f":"text","query":"c++"}}}},` +
+		`{"boost":{"b":0.5,"query":{"field":{"f":"text","query":"spring boot"}}}}]}}`
+	if b, err := json.Marshal(expanded.JSONQuery); err != nil || string(b) != expectedJSONQuery {
+		t.Errorf("JSONQuery = %s, %v, expected %s", b, err, expectedJSONQuery)
+	}
+
+	var expandedTerms []string
+	for _, e := range expanded.Expansions {
+		expandedTerms = append(expandedTerms, e.Term)
+	}
+	if !reflect.DeepEqual(expandedTerms, []string{"jvm", "c++", "spring boot"}) {
+		t.Errorf("Expansions = %v", expandedTerms)
+	}
+}
+
+func TestBuildExpandedQueryForms(t *testing.T) {
+	terms := []RelatedTerm{
+		{Term: "jvm", Relatedness: 0.9},
+		{Term: "kotlin", Relatedness: 0.5},
+	}
+
+	// Both forms are disjunctions of the original query and the added terms, with no required clause
+	expanded := buildExpandedQuery("java", "text", terms, 0.25, 5)
+	clauses := strings.Split(expanded.Query, " OR ")
+	for _, clause := range clauses {
+		if strings.HasPrefix(clause, "+") {
+			t.Errorf("Query = %s, expected no required clause", expanded.Query)
+		}
+	}
+	boolQuery := expanded.JSONQuery["bool"].(map[string]any)
+	for _, occur := range []string{"must", "filter"} {
+		if _, ok := boolQuery[occur]; ok {
+			t.Errorf("JSONQuery = %v, expected no %s clause", expanded.JSONQuery, occur)
+		}
+	}
+	if should := boolQuery["should"].([]any); len(should) != len(clauses) {
+		t.Errorf("JSONQuery has %d should clauses, expected %d as in %s", len(should), len(clauses), expanded.Query)
+	}
+}
+
+func TestBuildExpandedQueryThreshold(t *testing.T) {
+	terms := []RelatedTerm{
+		{Term: "coffee", Relatedness: 0.2},
+	}
+
+	expanded := buildExpandedQuery("java", "text", terms, 0.25, 5)
+	if expanded.Query != "(java)" || len(expanded.Expansions) != 0 {
+		t.Errorf("buildExpandedQuery() = %+v, expected no expansions", expanded)
+	}
+}
+
+func TestEscapeQueryTerm(t *testing.T) {
+	tests := []struct {
+		term     string
+		expected string
+	}{
+		{"java", "java"},
+		{"機械学習", "機械学習"},
+		{"c++", `c\+\+`},
+		{"a:b", `a\:b`},
+		{"spring boot", `"spring boot"`},
+		{`say "hi"`, `"say \"hi\""`},
+	}
+
+	for _, test := range tests {
+		if result := escapeQueryTerm(test.term); result != test.expected {
+			t.Errorf("escapeQueryTerm(%q) = %s, expected %s", test.term, result, test.expected)
+		}
+	}
+}
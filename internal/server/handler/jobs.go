@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/takatori/skg/internal/ingest"
+)
+
+// JobResponse is a feed job with its progress
+type JobResponse struct {
+	ingest.Job
+	ElapsedMs int64 `json:"elapsedMs"`
+}
+
+// NewJobResponse creates a JobResponse for the job at the given time
+func NewJobResponse(job ingest.Job, now time.Time) JobResponse {
+	return JobResponse{
+		Job:       job,
+		ElapsedMs: job.Elapsed(now).Milliseconds(),
+	}
+}
+
+// JobHandler handles requests for asynchronous feed jobs
+type JobHandler struct {
+	jobs *ingest.JobRunner
+}
+
+// NewJobHandler creates a new JobHandler for the jobs of the runner
+func NewJobHandler(jobs *ingest.JobRunner) *JobHandler {
+	return &JobHandler{
+		jobs: jobs,
+	}
+}
+
+// ListEndpoint returns an Echo handler function listing the jobs, most recent first
+func (h *JobHandler) ListEndpoint() func(echo.Context) error {
+	return func(c echo.Context) error {
+		jobs, err := h.jobs.List()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		response := make([]JobResponse, len(jobs))
+		for i, job := range jobs {
+			response[i] = NewJobResponse(job, now)
+		}
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// GetEndpoint returns an Echo handler function returning the progress of the job of the path
+func (h *JobHandler) GetEndpoint() func(echo.Context) error {
+	return func(c echo.Context) error {
+		job, err := h.jobs.Get(c.Param("id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, NewJobResponse(job, time.Now()))
+	}
+}
+
+// CancelEndpoint returns an Echo handler function cancelling the job of the path if it has not finished.
+// A running job stops after its batches being sent, so it is returned as accepted until then.
+func (h *JobHandler) CancelEndpoint() func(echo.Context) error {
+	return func(c echo.Context) error {
+		job, err := h.jobs.Cancel(c.Param("id"))
+		if err != nil {
+			return err
+		}
+		if job.State.Finished() {
+			return c.JSON(http.StatusOK, NewJobResponse(job, time.Now()))
+		}
+		return c.JSON(http.StatusAccepted, NewJobResponse(job, time.Now()))
+	}
+}
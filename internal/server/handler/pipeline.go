@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal/analysis"
+	"github.com/takatori/skg/internal/errors"
+	"github.com/takatori/skg/internal/ingest"
+)
+
+// PipelineHandler handles requests for managing named ingest pipelines
+type PipelineHandler struct {
+	analyzer  *analysis.Analyzer
+	pipelines *ingest.PipelineStore
+}
+
+// NewPipelineHandler creates a new PipelineHandler for the given store,
+// validating pipelines with the text analyzer used by their tokenize steps
+func NewPipelineHandler(analyzer *analysis.Analyzer, pipelines *ingest.PipelineStore) *PipelineHandler {
+	return &PipelineHandler{
+		analyzer:  analyzer,
+		pipelines: pipelines,
+	}
+}
+
+// ListEndpoint returns an Echo handler function listing the stored pipelines
+func (h *PipelineHandler) ListEndpoint() func(echo.Context) error {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, h.pipelines.List())
+	}
+}
+
+// GetEndpoint returns an Echo handler function returning the pipeline named by the path
+func (h *PipelineHandler) GetEndpoint() func(echo.Context) error {
+	return func(c echo.Context) error {
+		p, err := h.pipelines.Get(c.Param("name"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, p)
+	}
+}
+
+// PutEndpoint returns an Echo handler function storing the pipeline of the body under the name of the path,
+// after checking that it compiles
+func (h *PipelineHandler) PutEndpoint() func(echo.Context) error {
+	return func(c echo.Context) error {
+		var p ingest.Pipeline
+		if err := c.Bind(&p); err != nil {
+			return badRequest(err)
+		}
+		p.Name = c.Param("name")
+		if len(p.Steps) == 0 {
+			return failure.New(errors.ErrBadRequest, failure.Field(failure.Message("steps are required")))
+		}
+		if _, err := p.Compile(h.analyzer); err != nil {
+			return err
+		}
+		if err := h.pipelines.Put(p); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, p)
+	}
+}
+
+// DeleteEndpoint returns an Echo handler function deleting the pipeline named by the path
+func (h *PipelineHandler) DeleteEndpoint() func(echo.Context) error {
+	return func(c echo.Context) error {
+		if err := h.pipelines.Delete(c.Param("name")); err != nil {
+			return err
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
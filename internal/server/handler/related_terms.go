@@ -1,48 +1,117 @@
 package handler
 
 import (
+	"cmp"
+	"context"
+	"fmt"
 	"net/http"
-	"sort"
 
-	"github.com/ikawaha/kagome-dict/ipa"
-	"github.com/ikawaha/kagome/v2/tokenizer"
 	"github.com/labstack/echo/v4"
+	"github.com/morikuni/failure/v2"
 	"github.com/samber/lo"
 	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/analysis"
+	"github.com/takatori/skg/internal/errors"
 	"github.com/takatori/skg/internal/infra"
 	"github.com/takatori/skg/internal/skg"
 	"github.com/takatori/skg/internal/skg/solr"
 )
 
+// DocumentSetParams defines the optional foreground, background and filter queries
+// that relatedness is computed against
+type DocumentSetParams struct {
+	Foreground string   `json:"foreground"`
+	Background string   `json:"background"`
+	Filters    []string `json:"filters"`
+}
+
+// TraverseOptions converts the parameters into options for skg.SemanticKnowledgeGraph.Traverse
+func (p DocumentSetParams) TraverseOptions() skg.TraverseOptions {
+	return skg.TraverseOptions{
+		Foreground: p.Foreground,
+		Background: p.Background,
+		Filters:    p.Filters,
+	}
+}
+
+// defaultRelatedTermsLimit is the number of related terms returned by the related terms API
+const defaultRelatedTermsLimit = 8
+
+// Analysis modes of term extraction
+const (
+	// analysisLocal extracts terms with the Kagome analyzer of the server
+	analysisLocal = "local"
+	// analysisSolr extracts terms with the index analyzer of the target field in Solr and queries them
+	// as indexed terms, so that they always match; Profile, Units and NGram are ignored
+	analysisSolr = "solr"
+)
+
+// ExtractionParams defines how terms are extracted from documents
+type ExtractionParams struct {
+	Analysis string `json:"analysis"` // "local" or "solr"; defaults to the configured analysis
+	Profile  string `json:"profile"`  // the term extraction profile; defaults to analysis.DefaultProfile
+	Units    string `json:"units"`    // "tokens" (default), "compounds" or "both"
+	NGram    int    `json:"ngram"`    // if 2 or more, also extracts phrases of up to this many tokens
+	// Language is "ja", "en" or "auto" (default) to detect it from the document.
+	// It also selects the language's text field when no field is given.
+	Language string `json:"language"`
+}
+
+// ExtractOptions converts the parameters into options for analysis.Analyzer.Extractor
+func (p ExtractionParams) ExtractOptions() analysis.ExtractOptions {
+	return analysis.ExtractOptions{
+		Units:    analysis.Units(p.Units),
+		NGram:    p.NGram,
+		Language: p.Language,
+	}
+}
+
 // RelatedTermsParams defines the parameters for the related terms API
 type RelatedTermsParams struct {
 	Keyword    string `json:"keyword" validate:"required"`
 	Collection string `json:"collection" validate:"required"`
+	Field      string `json:"field"` // defaults to the collection's text field
+	Sort       string `json:"sort"`  // "relatedness" (default), "count" or "key"
+	Order      string `json:"order"` // "asc" or "desc"; defaults to "asc" for "key" and "desc" otherwise
+	DocumentSetParams
 }
 
 // RelatedTerm represents a single term related to the input keyword
 type RelatedTerm struct {
-	Term        string  `json:"term"`
-	Relatedness float64 `json:"relatedness"`
+	Term                 string  `json:"term"`
+	Relatedness          float64 `json:"relatedness"`
+	Count                int64   `json:"count"`
+	ForegroundPopularity float64 `json:"foregroundPopularity"`
+	BackgroundPopularity float64 `json:"backgroundPopularity"`
 }
 
 type CalcRelatednessParams struct {
 	Keyword    string `json:"keyword" validate:"required"`
 	Document   string `json:"document" validate:"required"`
 	Collection string `json:"collection" validate:"required"`
+	Field      string `json:"field"` // defaults to the collection's text field for the document's language
+	ExtractionParams
+	DocumentSetParams
 }
 
 // RelatedTermsHandler handles requests for related terms
 type RelatedTermsHandler struct {
 	config     *internal.Config
 	httpClient *infra.HttpClient
+	schema     *solr.SchemaInspector
+	analyzer   *analysis.Analyzer
+	fields     *solr.FieldAnalyzer
 }
 
-// NewRelatedTermsHandlerWithClient creates a new RelatedTermsHandler with the given config and HTTP client
-func NewRelatedTermsHandlerWithClient(config *internal.Config, httpClient *infra.HttpClient) *RelatedTermsHandler {
+// NewRelatedTermsHandlerWithClient creates a new RelatedTermsHandler with the given config, HTTP client
+// and text analyzer
+func NewRelatedTermsHandlerWithClient(config *internal.Config, httpClient *infra.HttpClient, analyzer *analysis.Analyzer) *RelatedTermsHandler {
 	return &RelatedTermsHandler{
 		config:     config,
 		httpClient: httpClient,
+		schema:     solr.NewSchemaInspector(config, httpClient),
+		analyzer:   analyzer,
+		fields:     solr.NewFieldAnalyzer(config, httpClient),
 	}
 }
 
@@ -52,17 +121,24 @@ func (h *RelatedTermsHandler) RelatedTermsEndpoint() func(echo.Context) error {
 		// Parse and validate request parameters
 		params, err := parseParams(c)
 		if err != nil {
-			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return badRequest(err)
+		}
+
+		// Resolve and validate the field against the collection schema
+		collection := resolveCollection(params.Collection)
+		field := resolveField(h.config, collection, params.Field)
+		if err := h.schema.ValidateFields(c.Request().Context(), collection, field); err != nil {
+			return err
 		}
 
 		// Build queries for the semantic knowledge graph
-		queries := buildQueries(params.Keyword)
+		queries := buildQueries(params.Keyword, field, defaultRelatedTermsLimit, skg.SortField(params.Sort), skg.SortDirection(params.Order))
 
 		// Query the semantic knowledge graph
 		skgInstance := solr.NewSolrSemanticKnowledgeGraphWithClient(h.config, h.httpClient)
-		result, err := skgInstance.Traverse(c.Request().Context(), queries, params.Collection)
+		result, err := skgInstance.Traverse(c.Request().Context(), queries, collection, params.TraverseOptions())
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return err
 		}
 
 		// Process results into related terms
@@ -77,43 +153,29 @@ func (h *RelatedTermsHandler) CalcRelatedness() func(echo.Context) error {
 	return func(c echo.Context) error {
 		params, err := parseCalcParams(c)
 		if err != nil {
-			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return badRequest(err)
 		}
 
-		// Use Kagome tokenizer with IPA dictionary for morphological analysis
-		t, err := tokenizer.New(ipa.Dict())
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to initialize tokenizer"})
+		// Resolve and validate the field against the collection schema
+		collection := resolveCollection(params.Collection)
+		language := analysis.ResolveLanguage(params.Language, params.Document)
+		field := resolveLanguageField(h.config, collection, language, params.Field)
+		if err := h.schema.ValidateFields(c.Request().Context(), collection, field); err != nil {
+			return err
 		}
-		tokens := t.Tokenize(params.Document)
-
-		// Extract meaningful words (nouns, verbs, adjectives, etc.)
-		var phrases []string
-		for _, token := range tokens {
-			// Skip punctuation, symbols, and other non-content tokens
-			if token.Class == tokenizer.DUMMY {
-				continue
-			}
-
-			// Get the base form of the word
-			features := token.Features()
-			if len(features) > 0 && features[0] != "名詞" {
-				continue
-			}
 
-			if len(features) > 6 && features[6] != "*" {
-				// Use base form if available
-				phrases = append(phrases, features[6])
-			} else {
-				// Otherwise use the surface form
-				phrases = append(phrases, token.Surface)
-			}
+		phrases, indexed, err := h.extractTerms(c.Request().Context(), collection, field, params.ExtractionParams, params.Document)
+		if err != nil {
+			return err
+		}
+		if len(phrases) == 0 {
+			return badRequest(fmt.Errorf("no terms could be extracted from the document"))
 		}
 
 		queries := [][]skg.Query{
 			{
 				{
-					Field: "text",
+					Field: field,
 					Values: []string{
 						params.Keyword,
 					},
@@ -121,43 +183,110 @@ func (h *RelatedTermsHandler) CalcRelatedness() func(echo.Context) error {
 			},
 			{
 				{
-					Field:  "text",
+					Field:  field,
 					Values: phrases,
+					Terms:  indexed,
+					Sort:   skg.SortByRelatedness,
 				},
 			},
 		}
 
 		skgInstance := solr.NewSolrSemanticKnowledgeGraphWithClient(h.config, h.httpClient)
-		result, err := skgInstance.Traverse(c.Request().Context(), queries, params.Collection)
+		result, err := skgInstance.Traverse(c.Request().Context(), queries, collection, params.TraverseOptions())
 
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return err
 		}
 
-		// Convert result to RelatedTerm objects
+		// Convert result to RelatedTerm objects, already sorted by relatedness in descending order
 		response := extractRelatedTermsForCalc(result)
 
-		// Sort by relatedness in descending order
-		sort.Slice(response, func(i, j int) bool {
-			return response[i].Relatedness > response[j].Relatedness
-		})
-
 		return c.JSON(http.StatusOK, response)
 	}
 }
 
+// extractTerms extracts the unique candidate terms of the document with the analysis chosen by the parameters,
+// analyzing it as the field of the collection for Solr-side analysis. It reports whether the terms are
+// indexed terms of the field, which must be queried as-is rather than analyzed again.
+func (h *RelatedTermsHandler) extractTerms(ctx context.Context, collection, field string, params ExtractionParams, document string) ([]string, bool, error) {
+	switch mode := cmp.Or(params.Analysis, h.config.ExtractionAnalysis, analysisLocal); mode {
+	case analysisLocal:
+		extractor, err := h.analyzer.Extractor(params.Profile, params.ExtractOptions())
+		if err != nil {
+			return nil, false, err
+		}
+		return lo.Uniq(extractor.Extract(document)), false, nil
+	case analysisSolr:
+		terms, err := h.fields.Analyze(ctx, collection, field, document)
+		if err != nil {
+			return nil, false, err
+		}
+		return lo.Uniq(terms), true, nil
+	default:
+		return nil, false, failure.New(
+			errors.ErrBadRequest,
+			failure.Field(failure.Messagef("invalid analysis: %q", mode)),
+			failure.Context{
+				"analysis": mode,
+			},
+		)
+	}
+}
+
 // For backward compatibility
 func NewRelatedTermsHandler(config *internal.Config) func(echo.Context) error {
-	handler := NewRelatedTermsHandlerWithClient(config, infra.NewHttpClient())
+	analyzer, err := analysis.NewAnalyzer(config)
+	if err != nil {
+		return func(c echo.Context) error {
+			return err
+		}
+	}
+	httpClient, err := infra.NewHttpClient(config)
+	if err != nil {
+		return func(c echo.Context) error {
+			return err
+		}
+	}
+	handler := NewRelatedTermsHandlerWithClient(config, httpClient, analyzer)
 	return handler.RelatedTermsEndpoint()
 }
 
+// resolveCollection returns the collection or the default collection if empty
+func resolveCollection(collection string) string {
+	if collection == "" {
+		return solr.DefaultCollection
+	}
+	return collection
+}
+
+// resolveField returns the field or the collection's text field if empty
+func resolveField(config *internal.Config, collection, field string) string {
+	if field == "" {
+		return config.TextField(collection)
+	}
+	return field
+}
+
+// resolveLanguageField returns the field or the collection's text field for the language if empty
+func resolveLanguageField(config *internal.Config, collection, language, field string) string {
+	if field == "" {
+		return config.LanguageTextField(collection, language)
+	}
+	return field
+}
+
 // parseParams extracts and validates the request parameters
 func parseParams(c echo.Context) (RelatedTermsParams, error) {
 	var params RelatedTermsParams
 	if err := c.Bind(&params); err != nil {
 		return params, err
 	}
+	if !skg.SortField(params.Sort).IsValid() {
+		return params, fmt.Errorf("invalid sort: %q", params.Sort)
+	}
+	if !skg.SortDirection(params.Order).IsValid() {
+		return params, fmt.Errorf("invalid order: %q", params.Order)
+	}
 	return params, nil
 }
 
@@ -169,12 +298,13 @@ func parseCalcParams(c echo.Context) (CalcRelatednessParams, error) {
 	return params, nil
 }
 
-// buildQueries constructs the query structure for the semantic knowledge graph
-func buildQueries(keyword string) [][]skg.Query {
+// buildQueries constructs the query structure for the semantic knowledge graph,
+// returning up to limit terms related to the keyword
+func buildQueries(keyword, field string, limit int, sortField skg.SortField, sortDirection skg.SortDirection) [][]skg.Query {
 	return [][]skg.Query{
 		{
 			{
-				Field: "text",
+				Field: field,
 				Values: []string{
 					keyword,
 				},
@@ -182,16 +312,18 @@ func buildQueries(keyword string) [][]skg.Query {
 		},
 		{
 			{
-				Field:         "text",
+				Field:         field,
 				MinOccurrence: lo.ToPtr(2),
-				Limit:         lo.ToPtr(8),
+				Limit:         lo.ToPtr(limit),
+				Sort:          sortField,
+				SortDirection: sortDirection,
 			},
 		},
 	}
 }
 
 // extractRelatedTerms processes the SKG result into a list of related terms
-func extractRelatedTerms(result map[string]skg.Traversal) []RelatedTerm {
+func extractRelatedTerms(result []skg.Traversal) []RelatedTerm {
 	var relatedTerms []RelatedTerm
 
 	for _, item := range result {
@@ -203,10 +335,7 @@ func extractRelatedTerms(result map[string]skg.Traversal) []RelatedTerm {
 		// Process each traversal in the first value
 		for _, traversal := range item.Values[0].Traversals {
 			for _, value := range traversal.Values {
-				relatedTerms = append(relatedTerms, RelatedTerm{
-					Term:        value.Key,
-					Relatedness: value.Relatedness,
-				})
+				relatedTerms = append(relatedTerms, newRelatedTerm(value))
 			}
 		}
 	}
@@ -215,7 +344,7 @@ func extractRelatedTerms(result map[string]skg.Traversal) []RelatedTerm {
 }
 
 // extractRelatedTermsForCalc processes the SKG result into a list of related terms for the CalcRelatedness function
-func extractRelatedTermsForCalc(result map[string]skg.Traversal) []RelatedTerm {
+func extractRelatedTermsForCalc(result []skg.Traversal) []RelatedTerm {
 	var relatedTerms []RelatedTerm
 
 	for _, item := range result {
@@ -227,13 +356,21 @@ func extractRelatedTermsForCalc(result map[string]skg.Traversal) []RelatedTerm {
 		// Process each traversal in the first value
 		for _, traversal := range item.Values[0].Traversals {
 			for _, value := range traversal.Values {
-				relatedTerms = append(relatedTerms, RelatedTerm{
-					Term:        value.Key,
-					Relatedness: value.Relatedness,
-				})
+				relatedTerms = append(relatedTerms, newRelatedTerm(value))
 			}
 		}
 	}
 
 	return relatedTerms
 }
+
+// newRelatedTerm converts an SKG node into a RelatedTerm
+func newRelatedTerm(node skg.Node) RelatedTerm {
+	return RelatedTerm{
+		Term:                 node.Key,
+		Relatedness:          node.Relatedness,
+		Count:                node.Count,
+		ForegroundPopularity: node.ForegroundPopularity,
+		BackgroundPopularity: node.BackgroundPopularity,
+	}
+}
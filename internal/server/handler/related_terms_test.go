@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/analysis"
+	"github.com/takatori/skg/internal/errors"
+	"github.com/takatori/skg/internal/infra"
+)
+
+func TestCalcRelatednessNoTerms(t *testing.T) {
+	solrServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/schema") {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"schema":{"fields":[{"name":"text_txt_ja"}]}}`))
+	}))
+	defer solrServer.Close()
+
+	config := &internal.Config{SolrUrl: solrServer.URL, HttpTimeout: time.Second, SchemaCacheTTL: time.Minute}
+	httpClient, err := infra.NewHttpClient(config)
+	if err != nil {
+		t.Fatalf("NewHttpClient() error = %v", err)
+	}
+	analyzer, err := analysis.NewAnalyzer(config)
+	if err != nil {
+		t.Fatalf("NewAnalyzer() error = %v", err)
+	}
+	h := NewRelatedTermsHandlerWithClient(config, httpClient, analyzer)
+
+	body := `{"keyword":"東京","document":"。、！？","collection":"test","field":"text_txt_ja","analysis":"local"}`
+	req := httptest.NewRequest(http.MethodPost, "/relatedness", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+
+	err = h.CalcRelatedness()(c)
+	if !failure.Is(err, errors.ErrBadRequest) {
+		t.Errorf("CalcRelatedness() error = %v, expected %v", err, errors.ErrBadRequest)
+	}
+}
@@ -4,18 +4,44 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/morikuni/failure/v2"
 	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/analysis"
+	"github.com/takatori/skg/internal/errors"
 	"github.com/takatori/skg/internal/infra"
+	"github.com/takatori/skg/internal/ingest"
+	"github.com/takatori/skg/internal/skg/solr"
 )
 
 // SolrSetupParams はSolrのセットアップに必要なパラメータを定義します
 type SolrSetupParams struct {
-	CollectionName    string `json:"collectionName" validate:"required"`
-	NumShards         int    `json:"numShards" validate:"required"`
-	ReplicationFactor int    `json:"replicationFactor" validate:"required"`
+	CollectionName    string   `json:"collectionName" validate:"required"`
+	NumShards         int      `json:"numShards" validate:"required"`
+	ReplicationFactor int      `json:"replicationFactor" validate:"required"`
+	ConfigName        string   `json:"configName"`      // collection.configName: 使用するconfigset (省略時はSolrのデフォルト)
+	Router            string   `json:"router"`          // router.name: "compositeId" (デフォルト) または "implicit"
+	RouterField       string   `json:"routerField"`     // router.field: ルーティングに使用するフィールド
+	Shards            []string `json:"shards"`          // implicit ルーターのシャード名
+	AutoAddReplicas   *bool    `json:"autoAddReplicas"` // Solr 8 まで対応
+}
+
+// SolrSchemaSpecParams は宣言的なスキーマ定義と適用先のコレクションを表します
+type SolrSchemaSpecParams struct {
+	CollectionName string          `json:"collectionName" validate:"required"`
+	Spec           solr.SchemaSpec `json:"spec"`
+}
+
+// SolrAliasParams はエイリアスが指すコレクションを定義します
+type SolrAliasParams struct {
+	Collections []string `json:"collections" validate:"required"`
 }
 
 // SolrSchemaField はコレクションのschemaに追加するフィールド定義を表します
@@ -37,141 +63,427 @@ type SolrSchemaParams struct {
 type SolrHandler struct {
 	config     *internal.Config
 	httpClient *infra.HttpClient
+	feeder     *ingest.Feeder
+	analyzer   *analysis.Analyzer
+	pipelines  *ingest.PipelineStore
+	jobs       *ingest.JobRunner
+	admin      *solr.CollectionsAdmin
+	schema     *solr.SchemaManager
 }
 
 // NewSolrHandler は新しいSolrHandlerを作成します
-func NewSolrHandler(config *internal.Config, httpClient *infra.HttpClient) *SolrHandler {
+// analyzer と pipelines はデータ投入時に適用するインジェストパイプラインに、jobs は非同期のデータ投入に使用します
+func NewSolrHandler(config *internal.Config, httpClient *infra.HttpClient, analyzer *analysis.Analyzer, pipelines *ingest.PipelineStore, jobs *ingest.JobRunner) *SolrHandler {
 	return &SolrHandler{
 		config:     config,
 		httpClient: httpClient,
+		feeder:     ingest.NewFeeder(config, httpClient),
+		analyzer:   analyzer,
+		pipelines:  pipelines,
+		jobs:       jobs,
+		admin:      solr.NewCollectionsAdmin(config, httpClient),
+		schema:     solr.NewSchemaManager(config, httpClient),
 	}
 }
 
 // SetupSolrHandler はApache Solrのセットアップを行うエンドポイントを返します
 // SolrCloudのCollectionを作成し、必要な設定を行います
+// 同名のコレクションが既に存在する場合は 409 Conflict を返します
 func (h *SolrHandler) SetupSolrHandler() func(echo.Context) error {
 	return func(c echo.Context) error {
 		var params SolrSetupParams
 		if err := c.Bind(&params); err != nil {
-			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+			return failure.Translate(err, errors.ErrBadRequest, failure.Field(failure.Message("Invalid request payload")))
+		}
+		if params.CollectionName == "" {
+			return failure.New(errors.ErrBadRequest, failure.Field(failure.Message("collectionName is required")))
 		}
 
-		solrURL := fmt.Sprintf("%s/admin/collections?action=CREATE&name=%s&numShards=%d&replicationFactor=%d",
-			h.config.SolrUrl,
-			params.CollectionName, params.NumShards, params.ReplicationFactor)
-
-		// Use the HTTP client to make the GET request
-		var solrResp map[string]interface{}
-		err := h.httpClient.Get(
-			c.Request().Context(),
-			infra.Request{
-				Url: solrURL,
-			},
-			&solrResp,
-		)
+		err := h.admin.Create(c.Request().Context(), solr.CreateCollectionOptions{
+			Name:              params.CollectionName,
+			NumShards:         params.NumShards,
+			ReplicationFactor: params.ReplicationFactor,
+			ConfigName:        params.ConfigName,
+			Router:            params.Router,
+			RouterField:       params.RouterField,
+			Shards:            params.Shards,
+			AutoAddReplicas:   params.AutoAddReplicas,
+		})
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create collection"})
+			return fmt.Errorf("failed to create collection: %w", err)
 		}
 
 		return c.JSON(http.StatusOK, map[string]string{"message": "Collection created successfully"})
 	}
 }
 
+// ListCollectionsHandler はコレクション名の一覧を返すエンドポイントを返します
+func (h *SolrHandler) ListCollectionsHandler() func(echo.Context) error {
+	return func(c echo.Context) error {
+		collections, err := h.admin.List(c.Request().Context())
+		if err != nil {
+			return fmt.Errorf("failed to list collections: %w", err)
+		}
+		return c.JSON(http.StatusOK, map[string][]string{"collections": collections})
+	}
+}
+
+// DescribeCollectionHandler はコレクションのシャード、レプリカ、ヘルスを返すエンドポイントを返します
+func (h *SolrHandler) DescribeCollectionHandler() func(echo.Context) error {
+	return func(c echo.Context) error {
+		status, err := h.admin.Describe(c.Request().Context(), c.Param("name"))
+		if err != nil {
+			return fmt.Errorf("failed to describe collection: %w", err)
+		}
+		return c.JSON(http.StatusOK, status)
+	}
+}
+
+// DeleteCollectionHandler はコレクションを削除するエンドポイントを返します
+func (h *SolrHandler) DeleteCollectionHandler() func(echo.Context) error {
+	return func(c echo.Context) error {
+		if err := h.admin.Delete(c.Request().Context(), c.Param("name")); err != nil {
+			return fmt.Errorf("failed to delete collection: %w", err)
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "Collection deleted successfully"})
+	}
+}
+
+// ReloadCollectionHandler はコレクションをリロードするエンドポイントを返します
+// configset やスキーマの変更を反映するために使用します
+func (h *SolrHandler) ReloadCollectionHandler() func(echo.Context) error {
+	return func(c echo.Context) error {
+		if err := h.admin.Reload(c.Request().Context(), c.Param("name")); err != nil {
+			return fmt.Errorf("failed to reload collection: %w", err)
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "Collection reloaded successfully"})
+	}
+}
+
+// ListAliasesHandler はエイリアスとそれが指すコレクションの一覧を返すエンドポイントを返します
+func (h *SolrHandler) ListAliasesHandler() func(echo.Context) error {
+	return func(c echo.Context) error {
+		aliases, err := h.admin.Aliases(c.Request().Context())
+		if err != nil {
+			return fmt.Errorf("failed to list aliases: %w", err)
+		}
+		return c.JSON(http.StatusOK, map[string]map[string][]string{"aliases": aliases})
+	}
+}
+
+// CreateAliasHandler はエイリアスを作成するエンドポイントを返します
+// 既存のエイリアスの場合は指すコレクションを置き換えます
+func (h *SolrHandler) CreateAliasHandler() func(echo.Context) error {
+	return func(c echo.Context) error {
+		var params SolrAliasParams
+		if err := c.Bind(&params); err != nil {
+			return failure.Translate(err, errors.ErrBadRequest, failure.Field(failure.Message("Invalid request payload")))
+		}
+		if len(params.Collections) == 0 {
+			return failure.New(errors.ErrBadRequest, failure.Field(failure.Message("collections is required")))
+		}
+
+		if err := h.admin.CreateAlias(c.Request().Context(), c.Param("name"), params.Collections); err != nil {
+			return fmt.Errorf("failed to create alias: %w", err)
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "Alias created successfully"})
+	}
+}
+
+// DeleteAliasHandler はエイリアスを削除するエンドポイントを返します
+func (h *SolrHandler) DeleteAliasHandler() func(echo.Context) error {
+	return func(c echo.Context) error {
+		if err := h.admin.DeleteAlias(c.Request().Context(), c.Param("name")); err != nil {
+			return fmt.Errorf("failed to delete alias: %w", err)
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "Alias deleted successfully"})
+	}
+}
+
 // SetupSolrSchemaHandler はSolrのコレクションのschemaを設定するエンドポイントを返します
+// 既に同じ定義のフィールドは変更せず、定義が異なるフィールドは置き換えるため、繰り返し実行できます
 func (h *SolrHandler) SetupSolrSchemaHandler() func(c echo.Context) error {
 	return func(c echo.Context) error {
 		var params SolrSchemaParams
 		if err := c.Bind(&params); err != nil {
-			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+			return failure.Translate(err, errors.ErrBadRequest, failure.Field(failure.Message("Invalid request payload")))
 		}
 
-		// Solr Schema API のエンドポイント
-		solrSchemaURL := fmt.Sprintf("%s/%s/schema", h.config.SolrUrl, params.CollectionName)
+		// 追加フィールドの定義をスキーマ定義に変換
+		var spec solr.SchemaSpec
+		for _, field := range params.Fields {
+			spec.Fields = append(spec.Fields, solr.Definition{
+				"name":        field.Name,
+				"type":        field.Type,
+				"stored":      field.Stored,
+				"indexed":     field.Indexed,
+				"multiValued": field.MultiValued,
+			})
+		}
 
-		// 追加フィールドの定義をpayloadとして作成
-		payload := map[string]interface{}{
-			"add-field": params.Fields,
+		if _, err := h.schema.Apply(c.Request().Context(), params.CollectionName, spec); err != nil {
+			return fmt.Errorf("failed to update schema: %w", err)
 		}
 
-		// Create a response map to hold the Solr response
-		var solrResp map[string]interface{}
+		return c.JSON(http.StatusOK, map[string]string{"message": "Schema updated successfully"})
+	}
+}
 
-		// Use the HTTP client to make the request
-		err := h.httpClient.Post(
-			c.Request().Context(),
-			infra.PostRequest{
-				Request: infra.Request{
-					Url: solrSchemaURL,
-					Headers: map[string]string{
-						"Content-Type": "application/json",
-					},
-				},
-				Entity: payload,
-			},
-			&solrResp,
-		)
+// PlanSolrSchemaHandler はスキーマ定義と現在のスキーマ (Schema API で取得) の差分を返すエンドポイントを返します
+// スキーマは変更しません
+func (h *SolrHandler) PlanSolrSchemaHandler() func(c echo.Context) error {
+	return func(c echo.Context) error {
+		params, err := parseSchemaSpecParams(c)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update schema"})
+			return err
 		}
 
-		return c.JSON(http.StatusOK, map[string]string{"message": "Schema updated successfully"})
+		plan, err := h.schema.Plan(c.Request().Context(), params.CollectionName, params.Spec)
+		if err != nil {
+			return fmt.Errorf("failed to plan schema: %w", err)
+		}
+		return c.JSON(http.StatusOK, plan)
 	}
 }
 
-// FeedSolrDataHandler はアップロードされたJSONファイルを読み込み、Solrのupdate APIにデータをフィードするエンドポイントを返します
+// ApplySolrSchemaHandler はスキーマ定義との差分を add/replace/delete コマンドとして適用するエンドポイントを返します
+// 適用した変更の一覧を返します
+func (h *SolrHandler) ApplySolrSchemaHandler() func(c echo.Context) error {
+	return func(c echo.Context) error {
+		params, err := parseSchemaSpecParams(c)
+		if err != nil {
+			return err
+		}
+
+		plan, err := h.schema.Apply(c.Request().Context(), params.CollectionName, params.Spec)
+		if err != nil {
+			return fmt.Errorf("failed to apply schema: %w", err)
+		}
+		return c.JSON(http.StatusOK, plan)
+	}
+}
+
+// parseSchemaSpecParams はスキーマ定義のリクエストを解析します
+func parseSchemaSpecParams(c echo.Context) (SolrSchemaSpecParams, error) {
+	var params SolrSchemaSpecParams
+	if err := c.Bind(&params); err != nil {
+		return params, failure.Translate(err, errors.ErrBadRequest, failure.Field(failure.Message("Invalid request payload")))
+	}
+	if params.CollectionName == "" {
+		return params, failure.New(errors.ErrBadRequest, failure.Field(failure.Message("collectionName is required")))
+	}
+	return params, nil
+}
+
+// FeedResponse はデータ投入の結果を表します
+type FeedResponse struct {
+	Message string `json:"message"`
+	Error   string `json:"error,omitempty"` // 投入が途中で失敗した場合のエラー
+	Code    string `json:"code,omitempty"`
+	ingest.Result
+}
+
+// FeedSolrDataHandler はアップロードされたファイルをストリーミングで読み込み、Solrのupdate APIにバッチ単位でデータをフィードするエンドポイントを返します
+// ファイル形式 (JSON, JSON Lines, CSV, Parquet) は format パラメータ、拡張子、Content-Type の順に判定します
+// batchSize, concurrency, commitWithin (ミリ秒) のフォームパラメータで投入方法を指定できます
+// CSV の列名は fieldMapping パラメータ (列名からフィールド名へのJSONオブジェクト) で変換できます
+// pipeline パラメータで保存済みのインジェストパイプラインを、pipelineSpec パラメータでJSONのパイプラインを指定でき、
+// どちらも指定されない場合はコレクションに設定されたパイプラインを適用します
+// 一部のバッチやドキュメントが失敗した場合は 207 Multi-Status と失敗の件数、失敗したバッチ・ドキュメントの一覧 (それぞれ先頭の100件まで) を返します
+// ファイルの読み込みなどで投入が中断した場合は、エラーのステータスとともにそれまでの結果を返します (送信済みのドキュメントはコミットされません)
+// async=true の場合はバックグラウンドのジョブとして投入し、202 Accepted とジョブを返します
+// ジョブの進捗は /solr/jobs/{id} で取得できます
 func (h *SolrHandler) FeedSolrDataHandler() func(c echo.Context) error {
 	return func(c echo.Context) error {
 		collectionName := c.FormValue("collectionName")
 		if collectionName == "" {
-			return c.JSON(http.StatusBadRequest, map[string]string{"error": "collectionName is required"})
+			return failure.New(errors.ErrBadRequest, failure.Field(failure.Message("collectionName is required")))
+		}
+
+		opts, err := parseFeedOptions(c, h.config)
+		if err != nil {
+			return err
+		}
+		sourceOpts, err := parseSourceOptions(c)
+		if err != nil {
+			return err
+		}
+		if opts.Transform, err = h.resolvePipeline(c, collectionName); err != nil {
+			return err
 		}
 
 		file, err := c.FormFile("file")
 		if err != nil {
-			return c.JSON(http.StatusBadRequest, map[string]string{"error": "file is required"})
+			return failure.Translate(err, errors.ErrBadRequest, failure.Field(failure.Message("file is required")))
+		}
+
+		format := ingest.DetectFormat(file.Filename, file.Header.Get("Content-Type"))
+		if name := c.FormValue("format"); name != "" {
+			if format, err = ingest.ParseFormat(name); err != nil {
+				return badRequest(err)
+			}
+		}
+
+		if value := c.FormValue("async"); value != "" {
+			async, err := strconv.ParseBool(value)
+			if err != nil {
+				return failure.New(errors.ErrBadRequest, failure.Field(failure.Messagef("async must be a boolean: %q", value)))
+			}
+			if async {
+				return h.submitFeedJob(c, collectionName, format, file, sourceOpts, opts)
+			}
 		}
 
 		f, err := file.Open()
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to open file"})
+			return failure.Translate(err, errors.ErrInternal, failure.Field(failure.Message("Failed to open file")))
 		}
 		defer f.Close()
 
-		fileBytes, err := io.ReadAll(f)
+		source, err := ingest.NewSource(format, f, file.Size, sourceOpts)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to read file"})
+			return badRequest(err)
 		}
 
-		// Solr update API のエンドポイント (commit=true)
-		solrUpdateURL := fmt.Sprintf("%s/%s/update?commit=true", h.config.SolrUrl, collectionName)
+		// ファイル全体をメモリに読み込まず、ドキュメントを逐次デコードしてバッチ単位で送信
+		result, err := h.feeder.Feed(c.Request().Context(), collectionName, source, opts)
+		if err != nil {
+			// エラーまでに送信したバッチの結果も返します。送信済みのドキュメントはコミットされていません
+			status := errorStatus(err)
+			if status >= http.StatusInternalServerError {
+				slog.Error("failed to feed data to Solr", "collection", collectionName, "error", err)
+			}
+			return c.JSON(status, FeedResponse{
+				Message: "Failed to feed data to Solr; documents indexed before the error are not committed",
+				Error:   errorMessage(err),
+				Code:    string(errorCode(err)),
+				Result:  result,
+			})
+		}
 
-		// Parse the file bytes into a JSON object
-		var jsonData interface{}
-		if err := json.Unmarshal(fileBytes, &jsonData); err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to parse JSON file"})
+		if result.DocumentsFailed > 0 {
+			return c.JSON(http.StatusMultiStatus, FeedResponse{Message: "Some documents failed to be fed to Solr", Result: result})
 		}
+		return c.JSON(http.StatusOK, FeedResponse{Message: "Data fed to Solr successfully", Result: result})
+	}
+}
 
-		// Create a response map to hold the Solr response
-		var solrResp interface{}
+// submitFeedJob はアップロードされたファイルを一時ファイルにコピーし、データ投入をバックグラウンドのジョブとして登録します
+// アップロードされたファイルはリクエストの終了時に削除されるため、一時ファイルはジョブの終了時に削除します
+func (h *SolrHandler) submitFeedJob(c echo.Context, collection string, format ingest.Format, file *multipart.FileHeader, sourceOpts ingest.SourceOptions, opts ingest.Options) error {
+	src, err := file.Open()
+	if err != nil {
+		return failure.Translate(err, errors.ErrInternal, failure.Field(failure.Message("Failed to open file")))
+	}
+	defer src.Close()
 
-		// Use the HTTP client to make the request
-		err = h.httpClient.Post(
-			c.Request().Context(),
-			infra.PostRequest{
-				Request: infra.Request{
-					Url: solrUpdateURL,
-					Headers: map[string]string{
-						"Content-Type": "application/json",
-					},
-				},
-				Entity: jsonData,
-			},
-			&solrResp,
-		)
+	tmp, err := os.CreateTemp("", "skg-feed-*")
+	if err != nil {
+		return failure.Translate(err, errors.ErrInternal, failure.Field(failure.Message("Failed to create temporary file")))
+	}
+	release := func() {
+		if err := tmp.Close(); err != nil {
+			slog.Warn("failed to close temporary file", "file", tmp.Name(), "error", err)
+		}
+		if err := os.Remove(tmp.Name()); err != nil {
+			slog.Warn("failed to remove temporary file", "file", tmp.Name(), "error", err)
+		}
+	}
+
+	size, err := io.Copy(tmp, src)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		release()
+		return failure.Translate(err, errors.ErrInternal, failure.Field(failure.Message("Failed to copy file")))
+	}
+
+	source, err := ingest.NewSource(format, tmp, size, sourceOpts)
+	if err != nil {
+		release()
+		return badRequest(err)
+	}
+
+	job, err := h.jobs.Submit(collection, source, opts, release)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set(echo.HeaderLocation, "/solr/jobs/"+job.ID)
+	return c.JSON(http.StatusAccepted, NewJobResponse(job, time.Now()))
+}
+
+// resolvePipeline はリクエストまたはコレクションの設定で指定されたインジェストパイプラインをコンパイルします
+// パイプラインが指定されていない場合は nil を返します
+func (h *SolrHandler) resolvePipeline(c echo.Context, collection string) (ingest.Transform, error) {
+	name, spec := c.FormValue("pipeline"), c.FormValue("pipelineSpec")
+	if name != "" && spec != "" {
+		return nil, failure.New(errors.ErrBadRequest, failure.Field(failure.Message("pipeline and pipelineSpec cannot be used together")))
+	}
+
+	var pipeline ingest.Pipeline
+	switch {
+	case spec != "":
+		if err := json.Unmarshal([]byte(spec), &pipeline); err != nil {
+			return nil, failure.Translate(err, errors.ErrBadRequest, failure.Field(failure.Message("pipelineSpec must be a JSON pipeline")))
+		}
+	case name != "":
+		p, err := h.pipelines.Get(name)
+		if err != nil {
+			return nil, failure.Translate(err, errors.ErrBadRequest, failure.Field(failure.Messagef("unknown pipeline: %q", name)))
+		}
+		pipeline = p
+	default:
+		name = h.config.CollectionPipelines[collection]
+		if name == "" {
+			return nil, nil
+		}
+		p, err := h.pipelines.Get(name)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to feed data to Solr"})
+			return nil, fmt.Errorf("failed to get the pipeline of collection %s: %w", collection, err)
+		}
+		pipeline = p
+	}
+	return pipeline.Compile(h.analyzer)
+}
+
+// parseSourceOptions はフォームパラメータで指定されたファイルの読み込み方法を解析します
+func parseSourceOptions(c echo.Context) (ingest.SourceOptions, error) {
+	var opts ingest.SourceOptions
+	if mapping := c.FormValue("fieldMapping"); mapping != "" {
+		if err := json.Unmarshal([]byte(mapping), &opts.FieldMapping); err != nil {
+			return opts, failure.Translate(err, errors.ErrBadRequest, failure.Field(failure.Message("fieldMapping must be a JSON object of strings")))
 		}
+	}
+	return opts, nil
+}
 
-		return c.JSON(http.StatusOK, map[string]string{"message": "Data fed to Solr successfully"})
+// parseFeedOptions はフォームパラメータで指定された投入方法を設定のデフォルト値に上書きします
+// batchSize と concurrency は設定の上限を超えられません
+func parseFeedOptions(c echo.Context, config *internal.Config) (ingest.Options, error) {
+	opts := ingest.DefaultOptions(config)
+	params := []struct {
+		name  string
+		limit int // 0 は上限なし
+		apply func(int)
+	}{
+		{"batchSize", config.FeedMaxBatchSize, func(v int) { opts.BatchSize = v }},
+		{"concurrency", config.FeedMaxConcurrency, func(v int) { opts.Concurrency = v }},
+		{"commitWithin", 0, func(v int) { opts.CommitWithin = time.Duration(v) * time.Millisecond }},
+	}
+	for _, param := range params {
+		value := c.FormValue(param.name)
+		if value == "" {
+			continue
+		}
+		v, err := strconv.Atoi(value)
+		if err != nil || v < 1 {
+			return opts, failure.New(errors.ErrBadRequest, failure.Field(failure.Messagef("%s must be a positive integer: %q", param.name, value)))
+		}
+		if param.limit > 0 && v > param.limit {
+			return opts, failure.New(errors.ErrBadRequest, failure.Field(failure.Messagef("%s must be at most %d: %q", param.name, param.limit, value)))
+		}
+		param.apply(v)
 	}
+	return opts, nil
 }
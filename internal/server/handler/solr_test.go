@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/infra"
+)
+
+func TestParseFeedOptions(t *testing.T) {
+	config := &internal.Config{FeedBatchSize: 1000, FeedConcurrency: 2, FeedMaxBatchSize: 5000, FeedMaxConcurrency: 4}
+	tests := []struct {
+		name                string
+		form                url.Values
+		expectedBatchSize   int
+		expectedConcurrency int
+		expectedErr         bool
+	}{
+		{"defaults", url.Values{}, 1000, 2, false},
+		{"overridden", url.Values{"batchSize": {"5000"}, "concurrency": {"4"}}, 5000, 4, false},
+		{"batch size above the limit", url.Values{"batchSize": {"5001"}}, 0, 0, true},
+		{"concurrency above the limit", url.Values{"concurrency": {"100"}}, 0, 0, true},
+		{"not positive", url.Values{"batchSize": {"0"}}, 0, 0, true},
+	}
+
+	e := echo.New()
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/solr/feed", strings.NewReader(test.form.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		c := e.NewContext(req, httptest.NewRecorder())
+
+		opts, err := parseFeedOptions(c, config)
+		if test.expectedErr {
+			if err == nil {
+				t.Errorf("parseFeedOptions(%s) expected an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseFeedOptions(%s) error = %v", test.name, err)
+			continue
+		}
+		if opts.BatchSize != test.expectedBatchSize || opts.Concurrency != test.expectedConcurrency {
+			t.Errorf("parseFeedOptions(%s) = %d, %d, expected %d, %d", test.name, opts.BatchSize, opts.Concurrency, test.expectedBatchSize, test.expectedConcurrency)
+		}
+	}
+}
+
+func TestFeedSolrDataHandlerPartialResult(t *testing.T) {
+	solrServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"responseHeader":{"status":0}}`))
+	}))
+	defer solrServer.Close()
+
+	config := &internal.Config{SolrUrl: solrServer.URL, HttpTimeout: time.Second, FeedBatchSize: 2, FeedConcurrency: 1}
+	httpClient, err := infra.NewHttpClient(config)
+	if err != nil {
+		t.Fatalf("NewHttpClient() error = %v", err)
+	}
+	h := NewSolrHandler(config, httpClient, nil, nil, nil)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("collectionName", "products")
+	file, _ := form.CreateFormFile("file", "products.json")
+	file.Write([]byte(`[{"id":"0"},{"id":"1"},{"id":"2"},oops]`))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/solr/feed", &body)
+	req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	if err := h.FeedSolrDataHandler()(c); err != nil {
+		t.Fatalf("FeedSolrDataHandler() error = %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, expected %d", rec.Code, http.StatusBadRequest)
+	}
+	var response FeedResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response %q: %v", rec.Body.String(), err)
+	}
+	if response.Documents != 2 || response.Committed || response.Code != "BadRequest" || response.Error == "" {
+		t.Errorf("response = %+v, expected 2 uncommitted documents and the error", response)
+	}
+}
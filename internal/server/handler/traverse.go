@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/infra"
+	"github.com/takatori/skg/internal/skg"
+	"github.com/takatori/skg/internal/skg/solr"
+)
+
+// TraverseQueryParams describes a single node of a hop in the traversal graph
+type TraverseQueryParams struct {
+	Name          string   `json:"name"`
+	Values        []string `json:"values"` // if non-empty, each value is queried; otherwise the field's terms are faceted
+	Field         string   `json:"field"`  // defaults to the collection's text field
+	Limit         *int     `json:"limit"`
+	MinCount      *int     `json:"minCount"`
+	MinPopularity *float64 `json:"minPopularity"` // the fraction of foreground documents, from 0 to 1
+	Operator      string   `json:"operator"`      // "AND" (default) or "OR"
+	Sort          string   `json:"sort"`          // "relatedness", "count" or "key"
+	Order         string   `json:"order"`         // "asc" or "desc"
+}
+
+// TraverseParams defines the parameters for the traverse API
+type TraverseParams struct {
+	Collection string                  `json:"collection" validate:"required"`
+	Graph      [][]TraverseQueryParams `json:"graph" validate:"required"` // one slice of nodes per hop
+	DocumentSetParams
+}
+
+// TraverseHandler handles requests for arbitrary semantic knowledge graph traversals
+type TraverseHandler struct {
+	config     *internal.Config
+	httpClient *infra.HttpClient
+	schema     *solr.SchemaInspector
+}
+
+// NewTraverseHandler creates a new TraverseHandler with the given config and HTTP client
+func NewTraverseHandler(config *internal.Config, httpClient *infra.HttpClient) *TraverseHandler {
+	return &TraverseHandler{
+		config:     config,
+		httpClient: httpClient,
+		schema:     solr.NewSchemaInspector(config, httpClient),
+	}
+}
+
+// TraverseEndpoint returns an Echo handler function that traverses the graph described in the request
+// and responds with the full nested traversal tree
+func (h *TraverseHandler) TraverseEndpoint() func(echo.Context) error {
+	return func(c echo.Context) error {
+		var params TraverseParams
+		if err := c.Bind(&params); err != nil {
+			return badRequest(err)
+		}
+
+		collection := resolveCollection(params.Collection)
+		queries, err := buildGraphQueries(params.Graph, h.config.TextField(collection))
+		if err != nil {
+			return badRequest(err)
+		}
+		if err := h.schema.ValidateFields(c.Request().Context(), collection, graphFields(queries)...); err != nil {
+			return err
+		}
+
+		skgInstance := solr.NewSolrSemanticKnowledgeGraphWithClient(h.config, h.httpClient)
+		result, err := skgInstance.Traverse(c.Request().Context(), queries, collection, params.TraverseOptions())
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, result)
+	}
+}
+
+// buildGraphQueries validates the graph and converts it into queries for the semantic knowledge graph,
+// using the default field for nodes without a field
+func buildGraphQueries(graph [][]TraverseQueryParams, defaultField string) ([][]skg.Query, error) {
+	if len(graph) == 0 {
+		return nil, fmt.Errorf("graph must have at least one hop")
+	}
+
+	queries := make([][]skg.Query, 0, len(graph))
+	for i, hop := range graph {
+		if len(hop) == 0 {
+			return nil, fmt.Errorf("hop %d must have at least one node", i)
+		}
+
+		nodes := make([]skg.Query, 0, len(hop))
+		for j, node := range hop {
+			if node.Field == "" {
+				node.Field = defaultField
+			}
+			if err := validateGraphNode(node); err != nil {
+				return nil, fmt.Errorf("hop %d node %d: %w", i, j, err)
+			}
+			nodes = append(nodes, skg.Query{
+				Name:            node.Name,
+				Values:          node.Values,
+				Field:           node.Field,
+				MinOccurrence:   node.MinCount,
+				Limit:           node.Limit,
+				MinPopularity:   node.MinPopularity,
+				DefaultOperator: strings.ToUpper(node.Operator),
+				Sort:            skg.SortField(node.Sort),
+				SortDirection:   skg.SortDirection(node.Order),
+			})
+		}
+		queries = append(queries, nodes)
+	}
+	return queries, nil
+}
+
+// validateGraphNode validates the settings of a single node of the graph
+func validateGraphNode(node TraverseQueryParams) error {
+	if node.Field == "" {
+		return fmt.Errorf("field is required")
+	}
+	if node.Limit != nil && *node.Limit < -1 {
+		return fmt.Errorf("limit must be -1 (unlimited) or greater: %d", *node.Limit)
+	}
+	if node.MinCount != nil && *node.MinCount < 0 {
+		return fmt.Errorf("minCount must not be negative: %d", *node.MinCount)
+	}
+	if node.MinPopularity != nil && (*node.MinPopularity < 0 || *node.MinPopularity > 1) {
+		return fmt.Errorf("minPopularity must be between 0 and 1: %g", *node.MinPopularity)
+	}
+	switch strings.ToUpper(node.Operator) {
+	case "", "AND", "OR":
+	default:
+		return fmt.Errorf("invalid operator: %q", node.Operator)
+	}
+	if !skg.SortField(node.Sort).IsValid() {
+		return fmt.Errorf("invalid sort: %q", node.Sort)
+	}
+	if !skg.SortDirection(node.Order).IsValid() {
+		return fmt.Errorf("invalid order: %q", node.Order)
+	}
+	return nil
+}
+
+// graphFields returns the distinct fields used by the queries in order of appearance
+func graphFields(queries [][]skg.Query) []string {
+	var fields []string
+	seen := map[string]bool{}
+	for _, hop := range queries {
+		for _, q := range hop {
+			if !seen[q.Field] {
+				seen[q.Field] = true
+				fields = append(fields, q.Field)
+			}
+		}
+	}
+	return fields
+}
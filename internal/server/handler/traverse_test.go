@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/takatori/skg/internal/skg"
+)
+
+func TestBuildGraphQueries(t *testing.T) {
+	graph := [][]TraverseQueryParams{
+		{{Values: []string{"java"}, Operator: "or"}},
+		{{Name: "category", Field: "category", Limit: lo.ToPtr(3), MinCount: lo.ToPtr(1)}},
+		{{Name: "brand", Field: "brand", MinPopularity: lo.ToPtr(0.002), Sort: "count"}},
+		{{Field: "text", Limit: lo.ToPtr(-1), Sort: "key", Order: "desc"}},
+	}
+
+	queries, err := buildGraphQueries(graph, "title_ja")
+	if err != nil {
+		t.Fatalf("buildGraphQueries() error = %v", err)
+	}
+	if len(queries) != 4 {
+		t.Fatalf("Expected 4 hops, got %d", len(queries))
+	}
+	if q := queries[0][0]; q.DefaultOperator != "OR" || len(q.Values) != 1 || q.Field != "title_ja" {
+		t.Errorf("hop 0 = %+v, expected operator OR with 1 value on the default field", q)
+	}
+	if fields := graphFields(queries); !reflect.DeepEqual(fields, []string{"title_ja", "category", "brand", "text"}) {
+		t.Errorf("graphFields() = %v", fields)
+	}
+	if q := queries[1][0]; q.Name != "category" || *q.Limit != 3 || *q.MinOccurrence != 1 {
+		t.Errorf("hop 1 = %+v, expected category with limit 3 and mincount 1", q)
+	}
+	if q := queries[2][0]; *q.MinPopularity != 0.002 || q.Sort != skg.SortByCount {
+		t.Errorf("hop 2 = %+v, expected min popularity 0.002 sorted by count", q)
+	}
+	if q := queries[3][0]; q.Sort != skg.SortByKey || q.SortDirection != skg.Descending {
+		t.Errorf("hop 3 = %+v, expected key descending", q)
+	}
+}
+
+func TestBuildGraphQueriesInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph [][]TraverseQueryParams
+	}{
+		{"empty graph", nil},
+		{"empty hop", [][]TraverseQueryParams{{{Field: "text"}}, {}}},
+		{"invalid limit", [][]TraverseQueryParams{{{Field: "text", Limit: lo.ToPtr(-2)}}}},
+		{"negative mincount", [][]TraverseQueryParams{{{Field: "text", MinCount: lo.ToPtr(-1)}}}},
+		{"negative min popularity", [][]TraverseQueryParams{{{Field: "text", MinPopularity: lo.ToPtr(-0.1)}}}},
+		{"min popularity above 1", [][]TraverseQueryParams{{{Field: "text", MinPopularity: lo.ToPtr(2.0)}}}},
+		{"invalid operator", [][]TraverseQueryParams{{{Field: "text", Operator: "XOR"}}}},
+		{"invalid sort", [][]TraverseQueryParams{{{Field: "text", Sort: "popularity"}}}},
+		{"invalid order", [][]TraverseQueryParams{{{Field: "text", Order: "up"}}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := buildGraphQueries(test.graph, "text"); err == nil {
+				t.Errorf("buildGraphQueries() expected an error")
+			}
+		})
+	}
+}
@@ -3,27 +3,89 @@ package server
 import (
 	"github.com/labstack/echo/v4"
 	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/analysis"
 	"github.com/takatori/skg/internal/infra"
+	"github.com/takatori/skg/internal/ingest"
 	"github.com/takatori/skg/internal/server/handler"
 )
 
-func InitServer(config *internal.Config) (*echo.Echo, error) {
+// InitServer creates the server with its routes, and a function that releases its resources,
+// to call once the server has shut down
+func InitServer(config *internal.Config) (*echo.Echo, func(), error) {
 	e := echo.New()
+	e.HTTPErrorHandler = handler.NewErrorHandler()
 
 	// Create a shared HTTP client
-	httpClient := infra.NewHttpClient()
+	httpClient, err := infra.NewHttpClient(config)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// Create handlers with the shared HTTP client
-	solrHandler := handler.NewSolrHandler(config, httpClient)
-	relatedTermsHandler := handler.NewRelatedTermsHandlerWithClient(config, httpClient)
+	// Create a shared text analyzer, loading its dictionary once at startup
+	var profiles []analysis.Profile
+	if config.ExtractionProfilesPath != "" {
+		loaded, err := analysis.LoadProfiles(config.ExtractionProfilesPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		profiles = loaded
+	}
+	analyzer, err := analysis.NewAnalyzer(config, profiles...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Load the named ingest pipelines, checking that they compile with the analyzer
+	pipelines, err := ingest.NewPipelineStore(config.IngestPipelinesPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, p := range pipelines.List() {
+		if _, err := p.Compile(analyzer); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Start the workers of asynchronous feed jobs, stopped by the returned function
+	jobs := ingest.NewJobRunner(config, ingest.NewFeeder(config, httpClient), ingest.NewMemoryJobStore(config.FeedJobRetention))
+
+	// Create handlers with the shared HTTP client and text analyzer
+	solrHandler := handler.NewSolrHandler(config, httpClient, analyzer, pipelines, jobs)
+	relatedTermsHandler := handler.NewRelatedTermsHandlerWithClient(config, httpClient, analyzer)
+	traverseHandler := handler.NewTraverseHandler(config, httpClient)
+	analysisHandler := handler.NewAnalysisHandler(analyzer)
+	pipelineHandler := handler.NewPipelineHandler(analyzer, pipelines)
+	jobHandler := handler.NewJobHandler(jobs)
 
 	// Register routes
 	e.GET("/health", handler.NewHealthHandler())
 	e.POST("/solr/setup", solrHandler.SetupSolrHandler())
+	e.GET("/solr/collections", solrHandler.ListCollectionsHandler())
+	e.POST("/solr/collections", solrHandler.SetupSolrHandler())
+	e.GET("/solr/collections/:name", solrHandler.DescribeCollectionHandler())
+	e.DELETE("/solr/collections/:name", solrHandler.DeleteCollectionHandler())
+	e.POST("/solr/collections/:name/reload", solrHandler.ReloadCollectionHandler())
+	e.GET("/solr/aliases", solrHandler.ListAliasesHandler())
+	e.PUT("/solr/aliases/:name", solrHandler.CreateAliasHandler())
+	e.DELETE("/solr/aliases/:name", solrHandler.DeleteAliasHandler())
 	e.POST("/solr/schema", solrHandler.SetupSolrSchemaHandler())
+	e.POST("/solr/schema/plan", solrHandler.PlanSolrSchemaHandler())
+	e.POST("/solr/schema/apply", solrHandler.ApplySolrSchemaHandler())
 	e.POST("/solr/feed", solrHandler.FeedSolrDataHandler())
+	e.GET("/solr/jobs", jobHandler.ListEndpoint())
+	e.GET("/solr/jobs/:id", jobHandler.GetEndpoint())
+	e.DELETE("/solr/jobs/:id", jobHandler.CancelEndpoint())
+	e.GET("/ingest/pipelines", pipelineHandler.ListEndpoint())
+	e.GET("/ingest/pipelines/:name", pipelineHandler.GetEndpoint())
+	e.PUT("/ingest/pipelines/:name", pipelineHandler.PutEndpoint())
+	e.DELETE("/ingest/pipelines/:name", pipelineHandler.DeleteEndpoint())
 	e.POST("/skg/relatedTerms", relatedTermsHandler.RelatedTermsEndpoint())
 	e.POST("/skg/calcRelatedness", relatedTermsHandler.CalcRelatedness())
+	e.POST("/skg/traverse", traverseHandler.TraverseEndpoint())
+	e.POST("/skg/disambiguate", relatedTermsHandler.Disambiguate())
+	e.POST("/skg/expandQuery", relatedTermsHandler.ExpandQuery())
+	e.POST("/skg/classify", relatedTermsHandler.Classify())
+	e.POST("/analysis/reload", analysisHandler.ReloadEndpoint())
 
-	return e, nil
+	return e, jobs.Close, nil
 }
@@ -3,26 +3,40 @@ package skg
 import "context"
 
 type Query struct {
-	Name            string   // optional; if empty a default name is assigned
-	Values          []string // if non-empty, a query facet is used; otherwise a terms facet is used
-	Field           string   // the field to query or faceting field
-	MinOccurrence   *int     // optional mincount (if provided)
-	Limit           *int     // optional limit on facet results; if nil a default is used
-	MinPopularity   *int     // optional min_popularity to be applied on nested facet
-	DefaultOperator string   // defaults to "AND" if empty
+	Name            string        // optional; if empty a default name is assigned
+	Values          []string      // if non-empty, a query facet is used; otherwise a terms facet is used
+	Terms           bool          // if true, Values are indexed terms of Field, matched as-is instead of being analyzed
+	Field           string        // the field to query or faceting field
+	MinOccurrence   *int          // optional mincount (if provided)
+	Limit           *int          // optional limit on facet results; if nil a default is used
+	MinPopularity   *float64      // optional min_popularity, from 0 to 1, to be applied on nested facet
+	DefaultOperator string        // defaults to "AND" if empty
+	Sort            SortField     // optional; orders the node's values, see SortField
+	SortDirection   SortDirection // optional; see DefaultSortDirection
 }
 
 type Traversal struct {
-	Name   string
-	Values []Node
+	Name   string `json:"name"`
+	Values []Node `json:"values"`
 }
 
 type Node struct {
-	Key         string
-	Relatedness float64
-	Traversals  []Traversal
+	Key                  string      `json:"key"`
+	Relatedness          float64     `json:"relatedness"`
+	Count                int64       `json:"count"`                // number of documents in the node
+	ForegroundPopularity float64     `json:"foregroundPopularity"` // fraction of foreground documents matching the node
+	BackgroundPopularity float64     `json:"backgroundPopularity"` // fraction of background documents matching the node
+	Traversals           []Traversal `json:"traversals,omitempty"`
+}
+
+// TraverseOptions controls the document sets that relatedness is computed against.
+type TraverseOptions struct {
+	Foreground string   // optional foreground query; defaults to all documents
+	Background string   // optional background query; defaults to all documents
+	Filters    []string // optional filter queries restricting the documents that are traversed
 }
 
 type SemanticKnowledgeGraph interface {
-	Traverse(context.Context, [][]Query, string) (map[string]Traversal, error)
+	// Traverse returns the traversals of the first level of queries in request order.
+	Traverse(context.Context, [][]Query, string, TraverseOptions) ([]Traversal, error)
 }
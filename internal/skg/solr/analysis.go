@@ -0,0 +1,87 @@
+package solr
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/infra"
+)
+
+// FieldAnalyzer analyzes text with the index analyzer of a field through Solr's field analysis API,
+// so that the resulting terms are exactly the terms Solr indexes for the field.
+type FieldAnalyzer struct {
+	config     *internal.Config
+	httpClient *infra.HttpClient
+}
+
+// fieldAnalysisResponse is the response of the field analysis API with json.nl=flat, where the stages
+// of an analysis chain are a flat list alternating the name of each stage and the tokens it produced.
+type fieldAnalysisResponse struct {
+	Analysis struct {
+		FieldNames map[string]struct {
+			Index []interface{} `json:"index"`
+		} `json:"field_names"`
+	} `json:"analysis"`
+}
+
+// NewFieldAnalyzer creates a new FieldAnalyzer with the given config and HTTP client
+func NewFieldAnalyzer(config *internal.Config, httpClient *infra.HttpClient) *FieldAnalyzer {
+	return &FieldAnalyzer{
+		config:     config,
+		httpClient: httpClient,
+	}
+}
+
+// Analyze returns the terms of the final token stream of the field's index analyzer for the text,
+// in the order they appear.
+func (a *FieldAnalyzer) Analyze(ctx context.Context, collection, field, text string) ([]string, error) {
+	form := url.Values{}
+	form.Set("analysis.fieldname", field)
+	form.Set("analysis.fieldvalue", text)
+	form.Set("wt", "json")
+	form.Set("json.nl", "flat")
+
+	var resp fieldAnalysisResponse
+	err := a.httpClient.PostForm(
+		ctx,
+		infra.Request{
+			Url:        fmt.Sprintf("%s/%s/analysis/field", a.config.SolrUrl, collection),
+			Idempotent: true,
+		},
+		form,
+		&resp,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze field: %w", err)
+	}
+
+	analysis, ok := resp.Analysis.FieldNames[field]
+	if !ok {
+		return nil, fmt.Errorf("no analysis returned for field %q", field)
+	}
+	return finalTerms(analysis.Index), nil
+}
+
+// finalTerms returns the text of the tokens of the last stage of an analysis chain
+func finalTerms(stages []interface{}) []string {
+	for i := len(stages) - 1; i >= 0; i-- {
+		tokens, ok := stages[i].([]interface{})
+		if !ok {
+			continue
+		}
+		terms := make([]string, 0, len(tokens))
+		for _, t := range tokens {
+			token, ok := t.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if text, ok := token["text"].(string); ok && text != "" {
+				terms = append(terms, text)
+			}
+		}
+		return terms
+	}
+	return nil
+}
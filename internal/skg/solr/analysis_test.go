@@ -0,0 +1,50 @@
+package solr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/infra"
+)
+
+func TestFieldAnalyzerAnalyze(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/products/analysis/field" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if r.PostForm.Get("analysis.fieldvalue") == "" || r.PostForm.Get("json.nl") != "flat" {
+			t.Errorf("unexpected form %v", r.PostForm)
+		}
+		_, _ = w.Write([]byte(`{"analysis":{"field_types":{},"field_names":{"title_ja":{"index":[
+			"org.apache.lucene.analysis.ja.JapaneseTokenizer",[{"text":"機械"},{"text":"学習"},{"text":"の"},{"text":"本"}],
+			"org.apache.lucene.analysis.ja.JapanesePartOfSpeechStopFilter",[{"text":"機械"},{"text":"学習"},{"text":"本"}]
+		]}}}}`))
+	}))
+	defer server.Close()
+
+	config := &internal.Config{SolrUrl: server.URL}
+	httpClient, err := infra.NewHttpClient(config)
+	if err != nil {
+		t.Fatalf("NewHttpClient() error = %v", err)
+	}
+	analyzer := NewFieldAnalyzer(config, httpClient)
+	terms, err := analyzer.Analyze(context.Background(), "products", "title_ja", "機械学習の本")
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	expected := []string{"機械", "学習", "本"}
+	if !reflect.DeepEqual(terms, expected) {
+		t.Errorf("Analyze() = %v, expected %v", terms, expected)
+	}
+
+	if _, err := analyzer.Analyze(context.Background(), "products", "body", "本"); err == nil {
+		t.Errorf("Analyze() expected an error for a field missing from the response")
+	}
+}
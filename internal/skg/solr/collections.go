@@ -0,0 +1,366 @@
+package solr
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/errors"
+	"github.com/takatori/skg/internal/infra"
+)
+
+// CollectionsAdmin manages the collections and aliases of SolrCloud through the Collections API
+type CollectionsAdmin struct {
+	config     *internal.Config
+	httpClient *infra.HttpClient
+}
+
+// CreateCollectionOptions defines a collection to create
+type CreateCollectionOptions struct {
+	Name              string
+	NumShards         int
+	ReplicationFactor int
+	ConfigName        string   // the configset of the collection; defaults to Solr's default configset
+	Router            string   // "compositeId" (default) or "implicit"
+	RouterField       string   // the field routing documents to shards instead of their id
+	Shards            []string // the shard names of the implicit router
+	AutoAddReplicas   *bool    // replaces lost replicas automatically; removed in Solr 9
+}
+
+// CollectionStatus describes a collection of the cluster
+type CollectionStatus struct {
+	Name              string        `json:"name"`
+	ConfigName        string        `json:"configName"`
+	Router            string        `json:"router"`
+	ReplicationFactor int           `json:"replicationFactor"`
+	Health            string        `json:"health,omitempty"` // "GREEN", "YELLOW", "ORANGE" or "RED"
+	Aliases           []string      `json:"aliases"`
+	Shards            []ShardStatus `json:"shards"`
+}
+
+// ShardStatus describes a shard of a collection
+type ShardStatus struct {
+	Name     string          `json:"name"`
+	Range    string          `json:"range,omitempty"`
+	State    string          `json:"state"`
+	Health   string          `json:"health,omitempty"`
+	Replicas []ReplicaStatus `json:"replicas"`
+}
+
+// ReplicaStatus describes a replica of a shard
+type ReplicaStatus struct {
+	Name   string `json:"name"`
+	Core   string `json:"core"`
+	Node   string `json:"node"`
+	State  string `json:"state"`
+	Type   string `json:"type"`
+	Leader bool   `json:"leader"`
+}
+
+// collectionsResponse holds the parts of a Collections API response reporting failures,
+// which Solr may return with a 200 status when some nodes failed
+type collectionsResponse struct {
+	ResponseHeader struct {
+		Status int `json:"status"`
+	} `json:"responseHeader"`
+	Failure   map[string]any `json:"failure"`
+	Exception *struct {
+		Msg     string `json:"msg"`
+		RspCode int    `json:"rspCode"`
+	} `json:"exception"`
+}
+
+// err returns a failure for a response reporting a failure, or nil
+func (r collectionsResponse) err(action string) error {
+	var message string
+	switch {
+	case r.Exception != nil && r.Exception.Msg != "":
+		message = r.Exception.Msg
+	case len(r.Failure) > 0:
+		nodes := make([]string, 0, len(r.Failure))
+		for node := range r.Failure {
+			nodes = append(nodes, node)
+		}
+		slices.Sort(nodes)
+		failures := make([]string, len(nodes))
+		for i, node := range nodes {
+			failures[i] = fmt.Sprintf("%s: %v", node, r.Failure[node])
+		}
+		message = strings.Join(failures, "; ")
+	case r.ResponseHeader.Status != 0:
+		message = fmt.Sprintf("status %d", r.ResponseHeader.Status)
+	default:
+		return nil
+	}
+	return failure.New(
+		errors.ErrInternal,
+		failure.Field(failure.Messagef("%s failed: %s", action, message)),
+		failure.Context{
+			"action": action,
+		},
+	)
+}
+
+// clusterStatusResponse is the response of the CLUSTERSTATUS action.
+// Numbers and booleans of the cluster state are strings in some versions of Solr.
+type clusterStatusResponse struct {
+	collectionsResponse
+	Cluster struct {
+		Collections map[string]struct {
+			ConfigName string `json:"configName"`
+			Router     struct {
+				Name string `json:"name"`
+			} `json:"router"`
+			ReplicationFactor any      `json:"replicationFactor"`
+			Health            string   `json:"health"`
+			Aliases           []string `json:"aliases"`
+			Shards            map[string]struct {
+				Range    string `json:"range"`
+				State    string `json:"state"`
+				Health   string `json:"health"`
+				Replicas map[string]struct {
+					Core     string `json:"core"`
+					NodeName string `json:"node_name"`
+					State    string `json:"state"`
+					Type     string `json:"type"`
+					Leader   any    `json:"leader"`
+				} `json:"replicas"`
+			} `json:"shards"`
+		} `json:"collections"`
+	} `json:"cluster"`
+}
+
+// NewCollectionsAdmin creates a new CollectionsAdmin with the given config and HTTP client
+func NewCollectionsAdmin(config *internal.Config, httpClient *infra.HttpClient) *CollectionsAdmin {
+	return &CollectionsAdmin{
+		config:     config,
+		httpClient: httpClient,
+	}
+}
+
+// Create creates a collection
+func (a *CollectionsAdmin) Create(ctx context.Context, opts CreateCollectionOptions) error {
+	params := url.Values{}
+	params.Set("name", opts.Name)
+	params.Set("numShards", strconv.Itoa(opts.NumShards))
+	params.Set("replicationFactor", strconv.Itoa(opts.ReplicationFactor))
+	if opts.ConfigName != "" {
+		params.Set("collection.configName", opts.ConfigName)
+	}
+	if opts.Router != "" {
+		params.Set("router.name", opts.Router)
+	}
+	if opts.RouterField != "" {
+		params.Set("router.field", opts.RouterField)
+	}
+	if len(opts.Shards) > 0 {
+		params.Set("shards", strings.Join(opts.Shards, ","))
+	}
+	if opts.AutoAddReplicas != nil {
+		params.Set("autoAddReplicas", strconv.FormatBool(*opts.AutoAddReplicas))
+	}
+
+	var resp collectionsResponse
+	return a.do(ctx, "CREATE", params, false, &resp)
+}
+
+// List returns the names of the collections, sorted
+func (a *CollectionsAdmin) List(ctx context.Context) ([]string, error) {
+	var resp struct {
+		collectionsResponse
+		Collections []string `json:"collections"`
+	}
+	if err := a.do(ctx, "LIST", url.Values{}, true, &resp); err != nil {
+		return nil, err
+	}
+	collections := slices.Clone(resp.Collections)
+	slices.Sort(collections)
+	if collections == nil {
+		collections = []string{}
+	}
+	return collections, nil
+}
+
+// Describe returns the status of the collection with its shards and replicas
+func (a *CollectionsAdmin) Describe(ctx context.Context, collection string) (CollectionStatus, error) {
+	params := url.Values{}
+	params.Set("collection", collection)
+
+	var resp clusterStatusResponse
+	if err := a.do(ctx, "CLUSTERSTATUS", params, true, &resp); err != nil {
+		return CollectionStatus{}, err
+	}
+	// The collection of an alias is returned under its own name
+	state, ok := resp.Cluster.Collections[collection]
+	if !ok && len(resp.Cluster.Collections) == 1 {
+		for name, s := range resp.Cluster.Collections {
+			collection, state, ok = name, s, true
+		}
+	}
+	if !ok {
+		return CollectionStatus{}, failure.New(
+			errors.ErrNotFound,
+			failure.Field(failure.Messagef("collection %q not found", collection)),
+		)
+	}
+
+	status := CollectionStatus{
+		Name:              collection,
+		ConfigName:        state.ConfigName,
+		Router:            state.Router.Name,
+		ReplicationFactor: intValue(state.ReplicationFactor),
+		Health:            state.Health,
+		Aliases:           state.Aliases,
+		Shards:            make([]ShardStatus, 0, len(state.Shards)),
+	}
+	if status.Aliases == nil {
+		status.Aliases = []string{}
+	}
+	for name, shard := range state.Shards {
+		s := ShardStatus{
+			Name:     name,
+			Range:    shard.Range,
+			State:    shard.State,
+			Health:   shard.Health,
+			Replicas: make([]ReplicaStatus, 0, len(shard.Replicas)),
+		}
+		for name, replica := range shard.Replicas {
+			s.Replicas = append(s.Replicas, ReplicaStatus{
+				Name:   name,
+				Core:   replica.Core,
+				Node:   replica.NodeName,
+				State:  replica.State,
+				Type:   replica.Type,
+				Leader: boolValue(replica.Leader),
+			})
+		}
+		slices.SortFunc(s.Replicas, func(a, b ReplicaStatus) int { return strings.Compare(a.Name, b.Name) })
+		status.Shards = append(status.Shards, s)
+	}
+	slices.SortFunc(status.Shards, func(a, b ShardStatus) int { return strings.Compare(a.Name, b.Name) })
+	return status, nil
+}
+
+// Delete deletes the collection
+func (a *CollectionsAdmin) Delete(ctx context.Context, collection string) error {
+	params := url.Values{}
+	params.Set("name", collection)
+
+	var resp collectionsResponse
+	return a.do(ctx, "DELETE", params, false, &resp)
+}
+
+// Reload reloads the cores of the collection, e.g. to apply a changed configset
+func (a *CollectionsAdmin) Reload(ctx context.Context, collection string) error {
+	params := url.Values{}
+	params.Set("name", collection)
+
+	var resp collectionsResponse
+	return a.do(ctx, "RELOAD", params, true, &resp)
+}
+
+// Aliases returns the collections of each alias
+func (a *CollectionsAdmin) Aliases(ctx context.Context) (map[string][]string, error) {
+	var resp struct {
+		collectionsResponse
+		Aliases map[string]string `json:"aliases"`
+	}
+	if err := a.do(ctx, "LISTALIASES", url.Values{}, true, &resp); err != nil {
+		return nil, err
+	}
+	aliases := make(map[string][]string, len(resp.Aliases))
+	for alias, collections := range resp.Aliases {
+		aliases[alias] = strings.Split(collections, ",")
+	}
+	return aliases, nil
+}
+
+// CreateAlias points the alias to the collections, replacing the collections of an existing alias
+func (a *CollectionsAdmin) CreateAlias(ctx context.Context, alias string, collections []string) error {
+	params := url.Values{}
+	params.Set("name", alias)
+	params.Set("collections", strings.Join(collections, ","))
+
+	var resp collectionsResponse
+	return a.do(ctx, "CREATEALIAS", params, true, &resp)
+}
+
+// DeleteAlias deletes the alias
+func (a *CollectionsAdmin) DeleteAlias(ctx context.Context, alias string) error {
+	params := url.Values{}
+	params.Set("name", alias)
+
+	var resp collectionsResponse
+	return a.do(ctx, "DELETEALIAS", params, false, &resp)
+}
+
+// failureReporter is a Collections API response that can report a failure
+type failureReporter interface {
+	err(action string) error
+}
+
+// do sends the action to the Collections API and decodes the response into resp,
+// returning an error if Solr reports a failure in the response body
+func (a *CollectionsAdmin) do(ctx context.Context, action string, params url.Values, idempotent bool, resp failureReporter) error {
+	params.Set("action", action)
+	params.Set("wt", "json")
+
+	err := a.httpClient.Get(
+		ctx,
+		infra.Request{
+			Url:        fmt.Sprintf("%s/admin/collections?%s", a.config.SolrUrl, params.Encode()),
+			Idempotent: idempotent,
+			Timeout:    a.config.AdminTimeout,
+		},
+		resp,
+	)
+	if err != nil {
+		return translateCollectionsError(err, action)
+	}
+	return resp.err(action)
+}
+
+// translateCollectionsError converts the 400 Bad Request that Solr returns for missing and existing
+// collections and aliases into errors.ErrNotFound and errors.ErrConflict failures
+func translateCollectionsError(err error, action string) error {
+	if !failure.Is(err, errors.ErrBadRequest) {
+		return fmt.Errorf("%s failed: %w", action, err)
+	}
+	message := failure.MessageOf(err).String()
+	switch lower := strings.ToLower(message); {
+	case strings.Contains(lower, "already exists"):
+		return failure.Translate(err, errors.ErrConflict, failure.Field(failure.Message(message)))
+	case strings.Contains(lower, "not found") || strings.Contains(lower, "could not find") || strings.Contains(lower, "does not exist"):
+		return failure.Translate(err, errors.ErrNotFound, failure.Field(failure.Message(message)))
+	}
+	return fmt.Errorf("%s failed: %w", action, err)
+}
+
+// intValue returns the integer of a number or numeric string of the cluster state
+func intValue(v any) int {
+	switch v := v.(type) {
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	return 0
+}
+
+// boolValue returns the boolean of a boolean or boolean string of the cluster state
+func boolValue(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
@@ -0,0 +1,194 @@
+package solr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/morikuni/failure/v2"
+	"github.com/samber/lo"
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/errors"
+	"github.com/takatori/skg/internal/infra"
+)
+
+// newTestCollectionsAdmin returns a CollectionsAdmin whose Collections API requests are answered by the handler
+func newTestCollectionsAdmin(t *testing.T, handler http.HandlerFunc) *CollectionsAdmin {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin/collections" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	config := &internal.Config{SolrUrl: server.URL}
+	httpClient, err := infra.NewHttpClient(config)
+	if err != nil {
+		t.Fatalf("NewHttpClient() error = %v", err)
+	}
+	return NewCollectionsAdmin(config, httpClient)
+}
+
+func TestCollectionsAdminCreate(t *testing.T) {
+	var query url.Values
+	admin := newTestCollectionsAdmin(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		_, _ = w.Write([]byte(`{"responseHeader":{"status":0},"success":{}}`))
+	})
+
+	err := admin.Create(context.Background(), CreateCollectionOptions{
+		Name:              "products",
+		NumShards:         2,
+		ReplicationFactor: 1,
+		ConfigName:        "products_conf",
+		Router:            "implicit",
+		Shards:            []string{"a", "b"},
+		AutoAddReplicas:   lo.ToPtr(true),
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	expected := url.Values{
+		"action":                {"CREATE"},
+		"wt":                    {"json"},
+		"name":                  {"products"},
+		"numShards":             {"2"},
+		"replicationFactor":     {"1"},
+		"collection.configName": {"products_conf"},
+		"router.name":           {"implicit"},
+		"shards":                {"a,b"},
+		"autoAddReplicas":       {"true"},
+	}
+	if !reflect.DeepEqual(query, expected) {
+		t.Errorf("Create() query = %v, expected %v", query, expected)
+	}
+}
+
+func TestCollectionsAdminErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected errors.ErrorCode
+	}{
+		{
+			name:     "already exists",
+			status:   http.StatusBadRequest,
+			body:     `{"responseHeader":{"status":400},"exception":{"msg":"collection already exists: products","rspCode":400},"error":{"msg":"collection already exists: products","code":400}}`,
+			expected: errors.ErrConflict,
+		},
+		{
+			name:     "not found",
+			status:   http.StatusBadRequest,
+			body:     `{"responseHeader":{"status":400},"error":{"msg":"Could not find collection : products","code":400}}`,
+			expected: errors.ErrNotFound,
+		},
+		{
+			name:     "bad request",
+			status:   http.StatusBadRequest,
+			body:     `{"responseHeader":{"status":400},"error":{"msg":"numShards is a required param","code":400}}`,
+			expected: errors.ErrBadRequest,
+		},
+		{
+			name:     "node failure",
+			status:   http.StatusOK,
+			body:     `{"responseHeader":{"status":0},"failure":{"127.0.0.1:8983_solr":"Error CREATEing SolrCore 'products_shard1_replica_n1'"}}`,
+			expected: errors.ErrInternal,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			admin := newTestCollectionsAdmin(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				_, _ = w.Write([]byte(test.body))
+			})
+			err := admin.Create(context.Background(), CreateCollectionOptions{Name: "products", NumShards: 1, ReplicationFactor: 1})
+			if !failure.Is(err, test.expected) {
+				t.Errorf("Create() error = %v, expected %s", err, test.expected)
+			}
+		})
+	}
+}
+
+func TestCollectionsAdminDescribe(t *testing.T) {
+	// The cluster state of Solr 8, with numbers and booleans as strings
+	body := `{"responseHeader":{"status":0},"cluster":{"collections":{"products_v2":{
+		"configName":"products_conf","router":{"name":"compositeId"},"replicationFactor":"2","health":"YELLOW",
+		"aliases":["products"],
+		"shards":{
+			"shard2":{"range":"0-7fffffff","state":"active","health":"GREEN","replicas":{
+				"core_node4":{"core":"products_v2_shard2_replica_n3","node_name":"solr2:8983_solr","state":"active","type":"NRT","leader":"true"}}},
+			"shard1":{"range":"80000000-ffffffff","state":"active","health":"YELLOW","replicas":{
+				"core_node2":{"core":"products_v2_shard1_replica_n1","node_name":"solr1:8983_solr","state":"active","type":"NRT","leader":"true"},
+				"core_node3":{"core":"products_v2_shard1_replica_n2","node_name":"solr2:8983_solr","state":"down","type":"NRT"}}}
+		}}}}}`
+	admin := newTestCollectionsAdmin(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("action") != "CLUSTERSTATUS" {
+			t.Errorf("unexpected action %s", r.URL.Query().Get("action"))
+		}
+		_, _ = w.Write([]byte(body))
+	})
+
+	// The alias is resolved to its collection
+	status, err := admin.Describe(context.Background(), "products")
+	if err != nil {
+		t.Fatalf("Describe() error = %v", err)
+	}
+	expected := CollectionStatus{
+		Name:              "products_v2",
+		ConfigName:        "products_conf",
+		Router:            "compositeId",
+		ReplicationFactor: 2,
+		Health:            "YELLOW",
+		Aliases:           []string{"products"},
+		Shards: []ShardStatus{
+			{Name: "shard1", Range: "80000000-ffffffff", State: "active", Health: "YELLOW", Replicas: []ReplicaStatus{
+				{Name: "core_node2", Core: "products_v2_shard1_replica_n1", Node: "solr1:8983_solr", State: "active", Type: "NRT", Leader: true},
+				{Name: "core_node3", Core: "products_v2_shard1_replica_n2", Node: "solr2:8983_solr", State: "down", Type: "NRT"},
+			}},
+			{Name: "shard2", Range: "0-7fffffff", State: "active", Health: "GREEN", Replicas: []ReplicaStatus{
+				{Name: "core_node4", Core: "products_v2_shard2_replica_n3", Node: "solr2:8983_solr", State: "active", Type: "NRT", Leader: true},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("Describe() = %+v, expected %+v", status, expected)
+	}
+}
+
+func TestCollectionsAdminListAndAliases(t *testing.T) {
+	admin := newTestCollectionsAdmin(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("action") {
+		case "LIST":
+			_, _ = w.Write([]byte(`{"responseHeader":{"status":0},"collections":["products_v2","articles"]}`))
+		case "LISTALIASES":
+			_, _ = w.Write([]byte(`{"responseHeader":{"status":0},"aliases":{"products":"products_v2","all":"products_v2,articles"}}`))
+		default:
+			t.Errorf("unexpected action %s", r.URL.Query().Get("action"))
+		}
+	})
+
+	collections, err := admin.List(context.Background())
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if expected := []string{"articles", "products_v2"}; !reflect.DeepEqual(collections, expected) {
+		t.Errorf("List() = %v, expected %v", collections, expected)
+	}
+
+	aliases, err := admin.Aliases(context.Background())
+	if err != nil {
+		t.Fatalf("Aliases() error = %v", err)
+	}
+	expected := map[string][]string{"products": {"products_v2"}, "all": {"products_v2", "articles"}}
+	if !reflect.DeepEqual(aliases, expected) {
+		t.Errorf("Aliases() = %v, expected %v", aliases, expected)
+	}
+}
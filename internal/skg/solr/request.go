@@ -2,6 +2,8 @@ package solr
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/takatori/skg/internal/skg"
 )
@@ -9,9 +11,12 @@ import (
 // transformRequest generates a faceted Solr SKG request from one or more multi-nodes.
 // Each multi-node can be either a single Node or a slice of Node.
 // Subsequent nodes are nested as facets of their parent nodes.
-func transformRequest(multiNodes [][]skg.Query) map[string]interface{} {
+//
+// Facets and query parameters are named after the position of the node in the request
+// (see facetKey), never after skg.Query.Name, so any user-provided name round-trips safely.
+func transformRequest(multiNodes [][]skg.Query, opts skg.TraverseOptions) map[string]interface{} {
 
-	request := generateRequestRoot()
+	request := generateRequestRoot(opts)
 	params := request["params"].(map[string]interface{})
 	// Start with the root as the only parent node.
 	parentNodes := []map[string]interface{}{request}
@@ -20,10 +25,7 @@ func transformRequest(multiNodes [][]skg.Query) map[string]interface{} {
 		var currentFacets []map[string]interface{}
 
 		for j, node := range nodes {
-			if node.Name == "" {
-				node.Name = defaultNodeName(i, j)
-			}
-			facets := generateFacets(node.Name, node.Values, node.Field, node.MinOccurrence, node.Limit, node.MinPopularity, node.DefaultOperator)
+			facets := generateFacets(i, j, node)
 			currentFacets = append(currentFacets, facets...)
 
 			// Attach the generated facets to each parent node.
@@ -34,15 +36,14 @@ func transformRequest(multiNodes [][]skg.Query) map[string]interface{} {
 					parentNode["facet"] = facetField
 				}
 				for k, facet := range facets {
-					key := fmt.Sprintf("%s_%d", node.Name, k)
+					key := facetKey(i, j, k)
 					parentNode["facet"].(map[string]interface{})[key] = facet
 				}
 			}
 			// If the node has a values array, add query parameters to the root.
 			if len(node.Values) > 0 {
 				for k, value := range node.Values {
-					key := fmt.Sprintf("%s_%d_query", node.Name, k)
-					params[key] = value
+					params[queryParamName(i, j, k)] = value
 				}
 			}
 		}
@@ -52,8 +53,11 @@ func transformRequest(multiNodes [][]skg.Query) map[string]interface{} {
 	return request
 }
 
-// generateFacets returns a slice of facet definitions based on the provided node parameters.
-func generateFacets(name string, values []string, field string, minOccurrence *int, limit *int, minPopularity *int, defaultOperator string) []map[string]interface{} {
+// generateFacets returns a slice of facet definitions based on the parameters of the j-th node of level i.
+func generateFacets(i, j int, node skg.Query) []map[string]interface{} {
+	values, field := node.Values, node.Field
+	minOccurrence, limit, minPopularity := node.MinOccurrence, node.Limit, node.MinPopularity
+
 	// Choose facet type based on values.
 	facetType := "terms"
 	if len(values) > 0 {
@@ -69,9 +73,7 @@ func generateFacets(name string, values []string, field string, minOccurrence *i
 	baseFacet := map[string]interface{}{
 		"type":  facetType,
 		"limit": facLimit,
-		"sort": map[string]interface{}{
-			"relatedness": "desc",
-		},
+		"sort":  facetSort(node.Sort, node.SortDirection),
 		"facet": map[string]interface{}{
 			"relatedness": map[string]interface{}{
 				"type": "func",
@@ -104,9 +106,12 @@ func generateFacets(name string, values []string, field string, minOccurrence *i
 			delete(baseFacet, "limit")
 		}
 		// For each value, create a facet copy with the appropriate query.
-		for i := range values {
+		for k := range values {
 			facetCopy := deepCopyMap(baseFacet)
-			queryStr := fmt.Sprintf("{!edismax q.op=%s qf=%s v=$%s_%d_query}", getDefaultOperator(defaultOperator), field, name, i)
+			queryStr := fmt.Sprintf("{!edismax q.op=%s qf=%s v=$%s}", getDefaultOperator(node.DefaultOperator), field, queryParamName(i, j, k))
+			if node.Terms {
+				queryStr = fmt.Sprintf("{!term f=%s v=$%s}", field, queryParamName(i, j, k))
+			}
 			facetCopy["query"] = queryStr
 			facets = append(facets, facetCopy)
 		}
@@ -117,16 +122,45 @@ func generateFacets(name string, values []string, field string, minOccurrence *i
 }
 
 // generateRequestRoot creates the basic request structure.
-func generateRequestRoot() map[string]interface{} {
+// The foreground query is sent as the main query so that the traversal only visits
+// documents in the foreground set, while the background query is used as-is by relatedness().
+func generateRequestRoot(opts skg.TraverseOptions) map[string]interface{} {
+	params := map[string]interface{}{
+		"q":       orMatchAll(opts.Foreground),
+		"fore":    "{!${defType} v=$q}",
+		"back":    orMatchAll(opts.Background),
+		"defType": "edismax",
+	}
+	if len(opts.Filters) > 0 {
+		params["fq"] = opts.Filters
+	}
 	return map[string]interface{}{
-		"limit": 0,
-		"params": map[string]interface{}{
-			"q":       "*:*",
-			"fore":    "{!${defType} v=$q}",
-			"back":    "*:*",
-			"defType": "edismax",
-		},
-		"facet": map[string]interface{}{},
+		"limit":  0,
+		"params": params,
+		"facet":  map[string]interface{}{},
+	}
+}
+
+// orMatchAll returns the query or "*:*" if empty.
+func orMatchAll(query string) string {
+	if query == "" {
+		return "*:*"
+	}
+	return query
+}
+
+// facetSort returns the Solr facet sort for the given sort field and direction.
+// Relatedness descending is used when no sort field is given.
+func facetSort(field skg.SortField, direction skg.SortDirection) map[string]interface{} {
+	if field == "" {
+		field = skg.SortByRelatedness
+	}
+	sortKey := string(field)
+	if field == skg.SortByKey {
+		sortKey = "index"
+	}
+	return map[string]interface{}{
+		sortKey: string(skg.DefaultSortDirection(field, direction)),
 	}
 }
 
@@ -152,6 +186,47 @@ func deepCopyMap(m map[string]interface{}) map[string]interface{} {
 	return newMap
 }
 
+// facetKey returns the name of the facet for the k-th value of the j-th node of level i.
+// Terms facets have a single facet per node and always use k = 0.
+func facetKey(i, j, k int) string {
+	return fmt.Sprintf("n%d_%d_%d", i, j, k)
+}
+
+// queryParamName returns the name of the request parameter holding the k-th value of the j-th node of level i.
+func queryParamName(i, j, k int) string {
+	return facetKey(i, j, k) + "_query"
+}
+
+// parseFacetKey parses a facet name generated by facetKey.
+// It reports false for any other key, such as the statistics Solr adds to each facet.
+func parseFacetKey(key string) (i, j, k int, ok bool) {
+	rest, found := strings.CutPrefix(key, "n")
+	if !found {
+		return 0, 0, 0, false
+	}
+	parts := strings.Split(rest, "_")
+	if len(parts) != 3 {
+		return 0, 0, 0, false
+	}
+	indexes := make([]int, len(parts))
+	for idx, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || strconv.Itoa(n) != part {
+			return 0, 0, 0, false
+		}
+		indexes[idx] = n
+	}
+	return indexes[0], indexes[1], indexes[2], true
+}
+
+// nodeName returns the name of the node or a default name based on indices if it has none.
+func nodeName(node skg.Query, i, j int) string {
+	if node.Name == "" {
+		return defaultNodeName(i, j)
+	}
+	return node.Name
+}
+
 // defaultNodeName generates a default name based on indices.
 func defaultNodeName(i, j int) string {
 	if j == 0 {
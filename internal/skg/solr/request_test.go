@@ -0,0 +1,266 @@
+package solr
+
+import (
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/takatori/skg/internal/skg"
+)
+
+func TestGenerateRequestRoot(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     skg.TraverseOptions
+		expected map[string]interface{}
+	}{
+		{
+			name: "defaults",
+			opts: skg.TraverseOptions{},
+			expected: map[string]interface{}{
+				"q":       "*:*",
+				"fore":    "{!${defType} v=$q}",
+				"back":    "*:*",
+				"defType": "edismax",
+			},
+		},
+		{
+			name: "foreground, background and filters",
+			opts: skg.TraverseOptions{
+				Foreground: "category:electronics",
+				Background: "in_stock:true",
+				Filters:    []string{"lang:ja", "year:[2020 TO *]"},
+			},
+			expected: map[string]interface{}{
+				"q":       "category:electronics",
+				"fore":    "{!${defType} v=$q}",
+				"back":    "in_stock:true",
+				"defType": "edismax",
+				"fq":      []string{"lang:ja", "year:[2020 TO *]"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := generateRequestRoot(test.opts)
+			params, ok := request["params"].(map[string]interface{})
+			if !ok {
+				t.Fatalf("Expected params to be a map, got %T", request["params"])
+			}
+			if !reflect.DeepEqual(params, test.expected) {
+				t.Errorf("generateRequestRoot() params = %v, expected %v", params, test.expected)
+			}
+		})
+	}
+}
+
+func TestGenerateFacetsQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		node     skg.Query
+		expected string
+	}{
+		{"analyzed", skg.Query{Field: "text", Values: []string{"機械学習"}}, "{!edismax q.op=AND qf=text v=$n0_0_0_query}"},
+		{"indexed terms", skg.Query{Field: "text", Values: []string{"機械"}, Terms: true}, "{!term f=text v=$n0_0_0_query}"},
+	}
+
+	for _, test := range tests {
+		facets := generateFacets(0, 0, test.node)
+		if len(facets) != 1 || facets[0]["query"] != test.expected {
+			t.Errorf("generateFacets(%s) = %v, expected query %q", test.name, facets, test.expected)
+		}
+	}
+}
+
+func TestParseFacetKey(t *testing.T) {
+	tests := []struct {
+		key        string
+		i, j, k    int
+		expectedOk bool
+	}{
+		{facetKey(0, 0, 0), 0, 0, 0, true},
+		{facetKey(3, 12, 7), 3, 12, 7, true},
+		{"n1_2", 0, 0, 0, false},
+		{"n1_2_3_query", 0, 0, 0, false},
+		{"n01_2_3", 0, 0, 0, false},
+		{"n-1_2_3", 0, 0, 0, false},
+		{"f0_0", 0, 0, 0, false},
+		{"count", 0, 0, 0, false},
+		{"relatedness", 0, 0, 0, false},
+		{"", 0, 0, 0, false},
+	}
+
+	for _, test := range tests {
+		i, j, k, ok := parseFacetKey(test.key)
+		if ok != test.expectedOk || i != test.i || j != test.j || k != test.k {
+			t.Errorf("parseFacetKey(%q) = %d/%d/%d/%v, expected %d/%d/%d/%v",
+				test.key, i, j, k, ok, test.i, test.j, test.k, test.expectedOk)
+		}
+	}
+}
+
+// queryGraph is a randomly generated multi-level query graph for property tests.
+type queryGraph [][]skg.Query
+
+// trickyNames are names that collided with the facet naming scheme used previously.
+var trickyNames = []string{"", "product_type", "a_1", "f0", "f1_1", "_", "__", "x_0_query", "n0_0_0", "count", "relatedness", "val", "名詞_1"}
+
+// Generate implements quick.Generator.
+func (queryGraph) Generate(rand *rand.Rand, size int) reflect.Value {
+	randomName := func() string {
+		if rand.Intn(2) == 0 {
+			return trickyNames[rand.Intn(len(trickyNames))]
+		}
+		v, _ := quick.Value(reflect.TypeOf(""), rand)
+		return v.String()
+	}
+
+	levels := 1 + rand.Intn(3)
+	graph := make(queryGraph, levels)
+	for i := range graph {
+		nodes := 1 + rand.Intn(3)
+		for j := 0; j < nodes; j++ {
+			q := skg.Query{Name: randomName(), Field: "text"}
+			if rand.Intn(2) == 0 {
+				values := 1 + rand.Intn(3)
+				for k := 0; k < values; k++ {
+					q.Values = append(q.Values, randomName())
+				}
+			}
+			graph[i] = append(graph[i], q)
+		}
+	}
+	return reflect.ValueOf(graph)
+}
+
+// simulateResponse builds the facet response Solr would return for a request facet,
+// answering terms facets with a single bucket whose key is the given value.
+func simulateResponse(facet map[string]interface{}, value string) map[string]interface{} {
+	response := map[string]interface{}{
+		"count":       float64(1),
+		"relatedness": map[string]interface{}{"relatedness": 0.5},
+	}
+	children, _ := facet["facet"].(map[string]interface{})
+	for key, child := range children {
+		childFacet, ok := child.(map[string]interface{})
+		if !ok || key == "relatedness" {
+			continue
+		}
+		if childFacet["type"] == "terms" {
+			bucket := simulateResponse(childFacet, value)
+			bucket["val"] = value
+			response[key] = map[string]interface{}{"buckets": []interface{}{bucket}}
+		} else {
+			response[key] = simulateResponse(childFacet, value)
+		}
+	}
+	return response
+}
+
+// checkTraversals verifies that traversals at the given level match the graph's names and values.
+func checkTraversals(t *testing.T, graph queryGraph, level int, traversals []skg.Traversal) bool {
+	if len(traversals) != len(graph[level]) {
+		t.Logf("level %d: expected %d traversals, got %d", level, len(graph[level]), len(traversals))
+		return false
+	}
+	for j, traversal := range traversals {
+		q := graph[level][j]
+		if traversal.Name != nodeName(q, level, j) {
+			t.Logf("level %d: traversal[%d] = %q, expected %q", level, j, traversal.Name, nodeName(q, level, j))
+			return false
+		}
+
+		expectedKeys := q.Values
+		if len(expectedKeys) == 0 {
+			expectedKeys = []string{"bucket"}
+		}
+		if len(traversal.Values) != len(expectedKeys) {
+			t.Logf("level %d: traversal %q has %d values, expected %d", level, traversal.Name, len(traversal.Values), len(expectedKeys))
+			return false
+		}
+		for k, node := range traversal.Values {
+			if node.Key != expectedKeys[k] {
+				t.Logf("level %d: traversal %q value[%d] = %q, expected %q", level, traversal.Name, k, node.Key, expectedKeys[k])
+				return false
+			}
+			if level+1 < len(graph) && !checkTraversals(t, graph, level+1, node.Traversals) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestTransformRoundTripProperty(t *testing.T) {
+	property := func(graph queryGraph) bool {
+		request := transformRequest(graph, skg.TraverseOptions{})
+
+		// Round-trip through JSON as the request and response would over the wire.
+		encoded, err := json.Marshal(request)
+		if err != nil {
+			t.Logf("failed to encode request: %v", err)
+			return false
+		}
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Logf("failed to decode request: %v", err)
+			return false
+		}
+
+		converter := &ResponseConverter{
+			RequestParams: decoded["params"].(map[string]interface{}),
+			Queries:       graph,
+		}
+		traversals := converter.transformResponseFacet(simulateResponse(decoded, "bucket"), 0)
+		return checkTraversals(t, graph, 0, traversals)
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTransformRequestFacetNamesProperty(t *testing.T) {
+	// Every facet and query parameter is named after its position, whatever the query names are.
+	property := func(graph queryGraph) bool {
+		request := transformRequest(graph, skg.TraverseOptions{})
+		params := request["params"].(map[string]interface{})
+
+		for j, q := range graph[0] {
+			keys := len(q.Values)
+			if keys == 0 {
+				keys = 1
+			}
+			for k := 0; k < keys; k++ {
+				if _, ok := request["facet"].(map[string]interface{})[facetKey(0, j, k)]; !ok {
+					t.Logf("missing facet %s", facetKey(0, j, k))
+					return false
+				}
+			}
+		}
+		for i, nodes := range graph {
+			for j, q := range nodes {
+				for k, value := range q.Values {
+					if params[queryParamName(i, j, k)] != value {
+						t.Logf("param %s = %v, expected %q", queryParamName(i, j, k), params[queryParamName(i, j, k)], value)
+						return false
+					}
+				}
+			}
+		}
+		for key := range params {
+			if _, _, _, ok := parseFacetKey(key); ok {
+				t.Logf("param %s collides with a facet name", key)
+				return false
+			}
+		}
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
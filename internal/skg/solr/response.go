@@ -2,32 +2,29 @@ package solr
 
 import (
 	"fmt"
-	"strings"
+	"sort"
 
 	"github.com/takatori/skg/internal/skg"
 )
 
 type ResponseConverter struct {
 	RequestParams map[string]interface{}
+	Queries       [][]skg.Query // the request's queries, used to order and sort traversals
 }
 
-// transformResponseFacet processes a response node and creates traversal maps
-// by grouping related facets while ignoring specific keys.
-func (r *ResponseConverter) transformResponseFacet(node map[string]interface{}) map[string]skg.Traversal {
-	ignoredKeys := map[string]bool{
-		"count":       true,
-		"relatedness": true,
-		"val":         true,
-	}
-	traversals := make(map[string]skg.Traversal)
+// transformResponseFacet processes a response node at the given level and creates traversals
+// by grouping the facets generated for each request node (see facetKey), ignoring any other keys.
+// Traversals are returned in the order their queries appear in the request.
+func (r *ResponseConverter) transformResponseFacet(node map[string]interface{}, level int) []skg.Traversal {
+	traversals := make(map[int]*skg.Traversal)
+	valueIndexes := make(map[int][]int)
 
 	for fullName, data := range node {
-		if ignoredKeys[fullName] {
+		i, j, k, ok := parseFacetKey(fullName)
+		if !ok || i != level {
 			continue
 		}
 
-		name := removeSuffix(fullName)
-
 		// Skip non-map data
 		dataMap, ok := data.(map[string]interface{})
 		if !ok {
@@ -35,34 +32,68 @@ func (r *ResponseConverter) transformResponseFacet(node map[string]interface{})
 		}
 
 		// Initialize traversal if needed
-		traversal, exists := traversals[name]
+		traversal, exists := traversals[j]
 		if !exists {
-			traversal = skg.Traversal{
-				Name: name,
+			traversal = &skg.Traversal{
+				Name: r.nodeName(i, j),
 			}
+			traversals[j] = traversal
 		}
 
 		// Process buckets if they exist
 		if buckets, hasBuckets := dataMap["buckets"]; hasBuckets {
-			traversal.Values = r.processBuckets(buckets)
+			traversal.Values = r.processBuckets(buckets, level)
 		} else {
 			// Process single node
-			n := r.transformNode(dataMap)
-			if valueName, ok := r.RequestParams[fmt.Sprintf("%s_query", fullName)]; ok {
-				n.Key = valueName.(string)
+			n := r.transformNode(dataMap, level)
+			if valueName, ok := r.RequestParams[queryParamName(i, j, k)].(string); ok {
+				n.Key = valueName
 			}
 
 			traversal.Values = append(traversal.Values, n)
+			valueIndexes[j] = append(valueIndexes[j], k)
+		}
+	}
+
+	nodeIndexes := make([]int, 0, len(traversals))
+	for j := range traversals {
+		nodeIndexes = append(nodeIndexes, j)
+	}
+	sort.Ints(nodeIndexes)
+
+	result := make([]skg.Traversal, 0, len(traversals))
+	for _, j := range nodeIndexes {
+		traversal := traversals[j]
+		// Query facets arrive as separate keys, so restore the order of the request's values.
+		if indexes, ok := valueIndexes[j]; ok {
+			sort.Sort(byValueIndex{nodes: traversal.Values, indexes: indexes})
 		}
+		if query, ok := r.query(level, j); ok && query.Sort != "" {
+			skg.SortNodes(traversal.Values, query.Sort, query.SortDirection)
+		}
+		result = append(result, *traversal)
+	}
+
+	return result
+}
 
-		traversals[name] = traversal
+// query looks up the request query for the j-th node of the given level.
+func (r *ResponseConverter) query(level, j int) (skg.Query, bool) {
+	if level >= len(r.Queries) || j >= len(r.Queries[level]) {
+		return skg.Query{}, false
 	}
+	return r.Queries[level][j], true
+}
 
-	return traversals
+// nodeName returns the name of the j-th node of the given level,
+// falling back to the default name when the request's queries are unknown.
+func (r *ResponseConverter) nodeName(level, j int) string {
+	query, _ := r.query(level, j)
+	return nodeName(query, level, j)
 }
 
-// processBuckets extracts and transforms bucket data into Node values
-func (r *ResponseConverter) processBuckets(buckets interface{}) []skg.Node {
+// processBuckets extracts and transforms bucket data at the given level into Node values
+func (r *ResponseConverter) processBuckets(buckets interface{}, level int) []skg.Node {
 	bucketList, ok := buckets.([]interface{})
 	if !ok {
 		return nil
@@ -74,15 +105,15 @@ func (r *ResponseConverter) processBuckets(buckets interface{}) []skg.Node {
 		if !ok {
 			continue
 		}
-		values = append(values, r.transformNode(bucket))
+		values = append(values, r.transformNode(bucket, level))
 	}
 
 	return values
 }
 
-// transformNode converts a response node into an skg.Node structure
+// transformNode converts a response node at the given level into an skg.Node structure
 // with key, relatedness value, and nested traversals.
-func (r *ResponseConverter) transformNode(node map[string]interface{}) skg.Node {
+func (r *ResponseConverter) transformNode(node map[string]interface{}, level int) skg.Node {
 	var keyStr string
 	if val, ok := node["val"]; ok {
 		keyStr = fmt.Sprintf("%v", val)
@@ -90,17 +121,18 @@ func (r *ResponseConverter) transformNode(node map[string]interface{}) skg.Node
 		keyStr = ""
 	}
 	relatedness := extractRelatedness(node)
+	foregroundPopularity, backgroundPopularity := extractPopularity(node)
 
 	valueNode := skg.Node{
-		Key:         keyStr,
-		Relatedness: relatedness,
+		Key:                  keyStr,
+		Relatedness:          relatedness,
+		Count:                extractCount(node),
+		ForegroundPopularity: foregroundPopularity,
+		BackgroundPopularity: backgroundPopularity,
 	}
 
 	// Process nested traversals
-	subTraversals := r.transformResponseFacet(node)
-	for _, subTraversal := range subTraversals {
-		valueNode.Traversals = append(valueNode.Traversals, subTraversal)
-	}
+	valueNode.Traversals = append(valueNode.Traversals, r.transformResponseFacet(node, level+1)...)
 
 	return valueNode
 }
@@ -137,11 +169,36 @@ func extractRelatedness(node map[string]interface{}) float64 {
 	return relatedness
 }
 
-// removeSuffix removes the trailing "_" plus the last segment from a string.
-// For example "foo_1" becomes "foo". If no underscore is found, returns the original string.
-func removeSuffix(s string) string {
-	if idx := strings.LastIndex(s, "_"); idx != -1 {
-		return s[:idx]
+// extractCount retrieves the document count of a node if available
+func extractCount(node map[string]interface{}) int64 {
+	count, ok := node["count"].(float64)
+	if !ok || count <= 0 {
+		return 0
+	}
+	return int64(count)
+}
+
+// extractPopularity retrieves the foreground and background popularity of a node if available
+func extractPopularity(node map[string]interface{}) (float64, float64) {
+	relMap, ok := node["relatedness"].(map[string]interface{})
+	if !ok {
+		return 0.0, 0.0
 	}
-	return s
+
+	foreground, _ := relMap["foreground_popularity"].(float64)
+	background, _ := relMap["background_popularity"].(float64)
+	return foreground, background
+}
+
+// byValueIndex sorts nodes by the value index of the facet they were decoded from.
+type byValueIndex struct {
+	nodes   []skg.Node
+	indexes []int
+}
+
+func (b byValueIndex) Len() int           { return len(b.nodes) }
+func (b byValueIndex) Less(i, j int) bool { return b.indexes[i] < b.indexes[j] }
+func (b byValueIndex) Swap(i, j int) {
+	b.nodes[i], b.nodes[j] = b.nodes[j], b.nodes[i]
+	b.indexes[i], b.indexes[j] = b.indexes[j], b.indexes[i]
 }
@@ -2,31 +2,12 @@ package solr
 
 import (
 	"encoding/json"
+	"reflect"
 	"testing"
 
 	"github.com/takatori/skg/internal/skg"
 )
 
-func TestRemoveSuffix(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{"f0_0", "f0"},
-		{"f1_0", "f1"},
-		{"noSuffix", "noSuffix"},
-		{"multiple_underscore_test_1", "multiple_underscore_test"},
-		{"", ""},
-	}
-
-	for _, test := range tests {
-		result := removeSuffix(test.input)
-		if result != test.expected {
-			t.Errorf("removeSuffix(%q) = %q, expected %q", test.input, result, test.expected)
-		}
-	}
-}
-
 func TestExtractRelatedness(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -99,6 +80,77 @@ func TestExtractRelatedness(t *testing.T) {
 	}
 }
 
+func TestExtractCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		node     map[string]interface{}
+		expected int64
+	}{
+		{"valid count", map[string]interface{}{"count": float64(100)}, 100},
+		{"no count", map[string]interface{}{}, 0},
+		{"count not float", map[string]interface{}{"count": "100"}, 0},
+		{"negative count", map[string]interface{}{"count": float64(-1)}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := extractCount(test.node)
+			if result != test.expected {
+				t.Errorf("extractCount() = %v, expected %v", result, test.expected)
+			}
+		})
+	}
+}
+
+func TestExtractPopularity(t *testing.T) {
+	tests := []struct {
+		name               string
+		node               map[string]interface{}
+		expectedForeground float64
+		expectedBackground float64
+	}{
+		{
+			name: "valid popularity",
+			node: map[string]interface{}{
+				"relatedness": map[string]interface{}{
+					"relatedness":           float64(0.75),
+					"foreground_popularity": float64(0.04947),
+					"background_popularity": float64(0.11965),
+				},
+			},
+			expectedForeground: 0.04947,
+			expectedBackground: 0.11965,
+		},
+		{
+			name:               "no relatedness map",
+			node:               map[string]interface{}{"count": float64(100)},
+			expectedForeground: 0.0,
+			expectedBackground: 0.0,
+		},
+		{
+			name: "popularity not float",
+			node: map[string]interface{}{
+				"relatedness": map[string]interface{}{
+					"foreground_popularity": "high",
+					"background_popularity": float64(0.5),
+				},
+			},
+			expectedForeground: 0.0,
+			expectedBackground: 0.5,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			foreground, background := extractPopularity(test.node)
+			if foreground != test.expectedForeground || background != test.expectedBackground {
+				t.Errorf("extractPopularity() = %v/%v, expected %v/%v",
+					foreground, background, test.expectedForeground, test.expectedBackground)
+			}
+		})
+	}
+}
+
 func TestTransformNode(t *testing.T) {
 	// Simple node with no nested traversals
 	simpleNode := map[string]interface{}{
@@ -116,7 +168,7 @@ func TestTransformNode(t *testing.T) {
 		"relatedness": map[string]interface{}{
 			"relatedness": float64(0.75),
 		},
-		"f1_0": map[string]interface{}{
+		"n1_0_0": map[string]interface{}{
 			"buckets": []interface{}{
 				map[string]interface{}{
 					"val":   "child1",
@@ -131,7 +183,7 @@ func TestTransformNode(t *testing.T) {
 
 	t.Run("simple node", func(t *testing.T) {
 		converter := &ResponseConverter{}
-		result := converter.transformNode(simpleNode)
+		result := converter.transformNode(simpleNode, 0)
 		expected := skg.Node{
 			Key:         "test",
 			Relatedness: 0.75,
@@ -141,11 +193,14 @@ func TestTransformNode(t *testing.T) {
 		if result.Key != expected.Key || result.Relatedness != expected.Relatedness {
 			t.Errorf("transformNode() = %+v, expected %+v", result, expected)
 		}
+		if result.Count != 100 {
+			t.Errorf("transformNode() count = %d, expected 100", result.Count)
+		}
 	})
 
 	t.Run("nested node", func(t *testing.T) {
 		converter := &ResponseConverter{}
-		result := converter.transformNode(nestedNode)
+		result := converter.transformNode(nestedNode, 0)
 		if result.Key != "parent" || result.Relatedness != 0.75 {
 			t.Errorf("transformNode() key/relatedness = %s/%f, expected parent/0.75", result.Key, result.Relatedness)
 		}
@@ -189,7 +244,7 @@ func TestProcessBuckets(t *testing.T) {
 	}
 
 	converter := &ResponseConverter{}
-	result := converter.processBuckets(buckets)
+	result := converter.processBuckets(buckets, 0)
 
 	if len(result) != 2 {
 		t.Fatalf("Expected 2 nodes, got %d", len(result))
@@ -204,7 +259,7 @@ func TestProcessBuckets(t *testing.T) {
 	}
 
 	// Test with invalid input
-	invalidResult := converter.processBuckets("not a slice")
+	invalidResult := converter.processBuckets("not a slice", 0)
 	if invalidResult != nil {
 		t.Errorf("Expected nil for invalid input, got %v", invalidResult)
 	}
@@ -215,14 +270,14 @@ func TestTransformResponseFacet(t *testing.T) {
 	jsonStr := `{
 		"facets":{
 			"count":200000,
-			"f0_0":{
+			"n0_0_0":{
 				"count":9905,
 				"relatedness":{
 					"relatedness":0.0,
 					"foreground_popularity":0.04953,
 					"background_popularity":0.04953
 				},
-				"f1_0":{
+				"n1_0_0":{
 					"buckets":[{
 						"val":"画",
 						"count":9894,
@@ -233,6 +288,7 @@ func TestTransformResponseFacet(t *testing.T) {
 						}
 					},{
 						"val":"像",
+						"count":2652,
 						"relatedness":{
 							"relatedness":0.79354,
 							"foreground_popularity":0.01326,
@@ -304,16 +360,16 @@ func TestTransformResponseFacet(t *testing.T) {
 	}
 
 	converter := &ResponseConverter{}
-	result := converter.transformResponseFacet(facets)
+	result := converter.transformResponseFacet(facets, 0)
 
 	// Verify the result structure
 	if len(result) != 1 {
 		t.Fatalf("Expected 1 traversal, got %d", len(result))
 	}
 
-	f0Traversal, exists := result["f0"]
-	if !exists {
-		t.Fatalf("Expected traversal with key 'f0', not found in %v", result)
+	f0Traversal := result[0]
+	if f0Traversal.Name != "f0" {
+		t.Fatalf("Expected traversal with name 'f0', got %q", f0Traversal.Name)
 	}
 
 	// Check f0 traversal values
@@ -364,14 +420,14 @@ func TestIntegration(t *testing.T) {
 	jsonStr := `{
 		"facets":{
 			"count":200000,
-			"f0_0":{
+			"n0_0_0":{
 				"count":9905,
 				"relatedness":{
 					"relatedness":0.0,
 					"foreground_popularity":0.04953,
 					"background_popularity":0.04953
 				},
-				"f1_0":{
+				"n1_0_0":{
 					"buckets":[{
 						"val":"画",
 						"count":9894,
@@ -382,6 +438,7 @@ func TestIntegration(t *testing.T) {
 						}
 					},{
 						"val":"像",
+						"count":2652,
 						"relatedness":{
 							"relatedness":0.79354,
 							"foreground_popularity":0.01326,
@@ -448,8 +505,9 @@ func TestIntegration(t *testing.T) {
 	}
 
 	// Expected structure after transformation
-	expectedTraversals := map[string]skg.Traversal{
-		"f0": {
+	expectedTraversals := []skg.Traversal{
+		{
+			Name: "f0",
 			Values: []skg.Node{
 				{
 					Relatedness: 0.0,
@@ -488,7 +546,7 @@ func TestIntegration(t *testing.T) {
 	converter := &ResponseConverter{
 		RequestParams: requestParams,
 	}
-	result := converter.transformResponseFacet(facets)
+	result := converter.transformResponseFacet(facets, 0)
 
 	// Compare the result with the expected structure
 	// Note: We're only checking the structure and key values, not doing a deep equality check
@@ -496,10 +554,11 @@ func TestIntegration(t *testing.T) {
 		t.Fatalf("Expected %d traversals, got %d", len(expectedTraversals), len(result))
 	}
 
-	for key, expectedTraversal := range expectedTraversals {
-		actualTraversal, exists := result[key]
-		if !exists {
-			t.Fatalf("Expected traversal with key '%s', not found", key)
+	for i, expectedTraversal := range expectedTraversals {
+		key := expectedTraversal.Name
+		actualTraversal := result[i]
+		if actualTraversal.Name != key {
+			t.Fatalf("Expected traversal with name '%s', got '%s'", key, actualTraversal.Name)
 		}
 
 		// Check values length
@@ -551,3 +610,93 @@ func almostEqual(a, b, tolerance float64) bool {
 	}
 	return diff <= tolerance
 }
+
+func TestTransformResponseFacetOrder(t *testing.T) {
+	// Facets arrive as an unordered map, so both traversal and value order must come from the request.
+	node := map[string]interface{}{
+		"count":     float64(100),
+		"n0_1_0":    map[string]interface{}{"count": float64(5), "relatedness": map[string]interface{}{"relatedness": 0.1}},
+		"n0_1_1":    map[string]interface{}{"count": float64(6), "relatedness": map[string]interface{}{"relatedness": 0.9}},
+		"n0_1_2":    map[string]interface{}{"count": float64(7), "relatedness": map[string]interface{}{"relatedness": 0.5}},
+		"n0_2_0":    map[string]interface{}{"count": float64(8), "relatedness": map[string]interface{}{"relatedness": 0.3}},
+		"n1_0_0":    map[string]interface{}{"count": float64(8)}, // facet of another level is ignored
+		"unrelated": map[string]interface{}{"count": float64(8)},
+		"n0_0_0": map[string]interface{}{
+			"buckets": []interface{}{
+				map[string]interface{}{"val": "y", "count": float64(3), "relatedness": map[string]interface{}{"relatedness": 0.2}},
+				map[string]interface{}{"val": "x", "count": float64(9), "relatedness": map[string]interface{}{"relatedness": 0.2}},
+				map[string]interface{}{"val": "z", "count": float64(1), "relatedness": map[string]interface{}{"relatedness": 0.7}},
+			},
+		},
+	}
+	params := map[string]interface{}{
+		"n0_1_0_query": "v0",
+		"n0_1_1_query": "v1",
+		"n0_1_2_query": "v2",
+		"n0_2_0_query": "w0",
+	}
+
+	tests := []struct {
+		name           string
+		queries        [][]skg.Query
+		expectedNames  []string
+		expectedValues [][]string
+	}{
+		{
+			name: "request order",
+			queries: [][]skg.Query{{
+				{Name: "c", Field: "text"},
+				{Name: "b", Field: "text", Values: []string{"v0", "v1", "v2"}},
+				{Name: "a", Field: "text", Values: []string{"w0"}},
+			}},
+			expectedNames:  []string{"c", "b", "a"},
+			expectedValues: [][]string{{"y", "x", "z"}, {"v0", "v1", "v2"}, {"w0"}},
+		},
+		{
+			name: "sorted values",
+			queries: [][]skg.Query{{
+				{Name: "c", Field: "text", Sort: skg.SortByCount, SortDirection: skg.Ascending},
+				{Name: "b", Field: "text", Values: []string{"v0", "v1", "v2"}, Sort: skg.SortByRelatedness},
+				{Name: "a", Field: "text", Values: []string{"w0"}},
+			}},
+			expectedNames:  []string{"c", "b", "a"},
+			expectedValues: [][]string{{"z", "y", "x"}, {"v1", "v2", "v0"}, {"w0"}},
+		},
+		{
+			name: "duplicate and default names",
+			queries: [][]skg.Query{{
+				{Field: "text"},
+				{Name: "f0", Field: "text", Values: []string{"v0", "v1", "v2"}},
+				{Field: "text", Values: []string{"w0"}},
+			}},
+			expectedNames:  []string{"f0", "f0", "f0_2"},
+			expectedValues: [][]string{{"y", "x", "z"}, {"v0", "v1", "v2"}, {"w0"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			converter := &ResponseConverter{RequestParams: params, Queries: test.queries}
+
+			// Repeat to catch nondeterminism from map iteration.
+			for n := 0; n < 20; n++ {
+				result := converter.transformResponseFacet(node, 0)
+				if len(result) != len(test.expectedNames) {
+					t.Fatalf("Expected %d traversals, got %d", len(test.expectedNames), len(result))
+				}
+				for i, traversal := range result {
+					if traversal.Name != test.expectedNames[i] {
+						t.Fatalf("traversal[%d] = %q, expected %q", i, traversal.Name, test.expectedNames[i])
+					}
+					var keys []string
+					for _, v := range traversal.Values {
+						keys = append(keys, v.Key)
+					}
+					if !reflect.DeepEqual(keys, test.expectedValues[i]) {
+						t.Fatalf("traversal %q values = %v, expected %v", traversal.Name, keys, test.expectedValues[i])
+					}
+				}
+			}
+		})
+	}
+}
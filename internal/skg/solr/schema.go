@@ -0,0 +1,147 @@
+package solr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/errors"
+	"github.com/takatori/skg/internal/infra"
+)
+
+// SchemaInspector looks up the fields of a collection's schema through the Schema API.
+// Schemas are cached per collection for config.SchemaCacheTTL.
+type SchemaInspector struct {
+	config     *internal.Config
+	httpClient *infra.HttpClient
+
+	mu    sync.Mutex
+	cache map[string]cachedSchema
+}
+
+type cachedSchema struct {
+	schema    schemaFields
+	fetchedAt time.Time
+}
+
+// schemaFields holds the names of the fields and the patterns of the dynamic fields of a schema.
+type schemaFields struct {
+	fields        map[string]bool
+	dynamicFields []string
+}
+
+type schemaResponse struct {
+	Schema struct {
+		Fields []struct {
+			Name string `json:"name"`
+		} `json:"fields"`
+		DynamicFields []struct {
+			Name string `json:"name"`
+		} `json:"dynamicFields"`
+	} `json:"schema"`
+}
+
+// NewSchemaInspector creates a new SchemaInspector with the given config and HTTP client
+func NewSchemaInspector(config *internal.Config, httpClient *infra.HttpClient) *SchemaInspector {
+	return &SchemaInspector{
+		config:     config,
+		httpClient: httpClient,
+		cache:      map[string]cachedSchema{},
+	}
+}
+
+// ValidateFields returns an errors.ErrBadRequest failure if any of the fields is neither defined
+// in the collection's schema nor matched by one of its dynamic fields.
+// A cached schema missing a field is fetched again, as the field may have been added since.
+func (s *SchemaInspector) ValidateFields(ctx context.Context, collection string, fields ...string) error {
+	schema, fresh, err := s.schema(ctx, collection, false)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		if !schema.has(field) && !fresh {
+			if schema, fresh, err = s.schema(ctx, collection, true); err != nil {
+				return err
+			}
+		}
+		if !schema.has(field) {
+			return failure.New(
+				errors.ErrBadRequest,
+				failure.Field(failure.Messagef("unknown field %q in collection %q", field, collection)),
+				failure.Context{
+					"collection": collection,
+					"field":      field,
+				},
+			)
+		}
+	}
+	return nil
+}
+
+// schema returns the cached schema of the collection, fetching it if missing, expired or refresh is set.
+// It reports whether the schema was fetched rather than cached.
+func (s *SchemaInspector) schema(ctx context.Context, collection string, refresh bool) (schemaFields, bool, error) {
+	s.mu.Lock()
+	cached, ok := s.cache[collection]
+	s.mu.Unlock()
+	if ok && !refresh && time.Since(cached.fetchedAt) < s.config.SchemaCacheTTL {
+		return cached.schema, false, nil
+	}
+
+	var resp schemaResponse
+	err := s.httpClient.Get(
+		ctx,
+		infra.Request{
+			Url:        fmt.Sprintf("%s/%s/schema?wt=json", s.config.SolrUrl, collection),
+			Idempotent: true,
+		},
+		&resp,
+	)
+	if err != nil {
+		return schemaFields{}, false, fmt.Errorf("failed to fetch schema: %w", err)
+	}
+
+	schema := schemaFields{fields: map[string]bool{}}
+	for _, f := range resp.Schema.Fields {
+		schema.fields[f.Name] = true
+	}
+	for _, f := range resp.Schema.DynamicFields {
+		schema.dynamicFields = append(schema.dynamicFields, f.Name)
+	}
+
+	s.mu.Lock()
+	s.cache[collection] = cachedSchema{schema: schema, fetchedAt: time.Now()}
+	s.mu.Unlock()
+
+	return schema, true, nil
+}
+
+// has reports whether the field is defined in the schema or matched by a dynamic field.
+func (s schemaFields) has(field string) bool {
+	if s.fields[field] {
+		return true
+	}
+	for _, pattern := range s.dynamicFields {
+		if matchDynamicField(pattern, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDynamicField reports whether the field matches a dynamic field pattern,
+// which has a single "*" at either the start or the end of the name.
+func matchDynamicField(pattern, field string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return len(field) > len(suffix) && strings.HasSuffix(field, suffix)
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return len(field) > len(prefix) && strings.HasPrefix(field, prefix)
+	}
+	return pattern == field
+}
@@ -0,0 +1,467 @@
+package solr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/errors"
+	"github.com/takatori/skg/internal/infra"
+)
+
+// PresetKuromoji is the preset of a Japanese text field type analyzed by Kuromoji,
+// as defined by the text_ja field type of the default configset of Solr 9
+const PresetKuromoji = "kuromoji"
+
+// Definition is the definition of a field type, field or dynamic field as sent to the Schema API,
+// e.g. {"name": "title", "type": "text_ja", "stored": true}
+type Definition map[string]any
+
+// Name returns the name of the definition
+func (d Definition) Name() string {
+	name, _ := d["name"].(string)
+	return name
+}
+
+// CopyField copies the values of the source field into the dest field
+type CopyField struct {
+	Source   string `json:"source"`
+	Dest     string `json:"dest"`
+	MaxChars int    `json:"maxChars,omitempty"`
+}
+
+// SchemaSpec is the desired state of a collection's schema. Definitions missing from the schema are added,
+// and definitions that differ are replaced; definitions of the schema missing from the spec are kept
+// unless they are listed in Delete.
+// A field type may be defined with "preset": "kuromoji" instead of its class and analyzer.
+type SchemaSpec struct {
+	FieldTypes    []Definition `json:"fieldTypes"`
+	Fields        []Definition `json:"fields"`
+	DynamicFields []Definition `json:"dynamicFields"`
+	CopyFields    []CopyField  `json:"copyFields"`
+	Delete        struct {
+		FieldTypes    []string    `json:"fieldTypes"`
+		Fields        []string    `json:"fields"`
+		DynamicFields []string    `json:"dynamicFields"`
+		CopyFields    []CopyField `json:"copyFields"`
+	} `json:"delete"`
+}
+
+// Schema change actions
+const (
+	ActionAdd     = "add"
+	ActionReplace = "replace"
+	ActionDelete  = "delete"
+)
+
+// Kinds of schema definitions, in the order their additions are applied
+const (
+	KindFieldType    = "field-type"
+	KindField        = "field"
+	KindDynamicField = "dynamic-field"
+	KindCopyField    = "copy-field"
+)
+
+// SchemaChange is a change of a schema, sent to the Schema API as the "<action>-<kind>" command
+type SchemaChange struct {
+	Action string `json:"action"`
+	Kind   string `json:"kind"`
+	Name   string `json:"name"` // the name of the definition, or "<source> -> <dest>" for copy fields
+	// Definition is the definition to add or replace with, or the definition to delete
+	Definition any `json:"definition"`
+	// Current is the definition in the schema that is replaced
+	Current any `json:"current,omitempty"`
+}
+
+// SchemaPlan is the list of changes that bring a collection's schema to its spec, in the order they are applied
+type SchemaPlan struct {
+	Collection string         `json:"collection"`
+	Changes    []SchemaChange `json:"changes"`
+}
+
+// SchemaManager plans and applies schema specs through the Schema API
+type SchemaManager struct {
+	config     *internal.Config
+	httpClient *infra.HttpClient
+}
+
+// liveSchema is the schema of a collection as returned by the Schema API
+type liveSchema struct {
+	FieldTypes    []Definition `json:"fieldTypes"`
+	Fields        []Definition `json:"fields"`
+	DynamicFields []Definition `json:"dynamicFields"`
+	CopyFields    []CopyField  `json:"copyFields"`
+}
+
+// NewSchemaManager creates a new SchemaManager with the given config and HTTP client
+func NewSchemaManager(config *internal.Config, httpClient *infra.HttpClient) *SchemaManager {
+	return &SchemaManager{
+		config:     config,
+		httpClient: httpClient,
+	}
+}
+
+// Plan returns the changes that bring the collection's schema to the spec
+func (m *SchemaManager) Plan(ctx context.Context, collection string, spec SchemaSpec) (SchemaPlan, error) {
+	spec, err := spec.normalize()
+	if err != nil {
+		return SchemaPlan{}, err
+	}
+
+	var resp struct {
+		Schema liveSchema `json:"schema"`
+	}
+	err = m.httpClient.Get(
+		ctx,
+		infra.Request{
+			Url:        fmt.Sprintf("%s/%s/schema?wt=json", m.config.SolrUrl, collection),
+			Idempotent: true,
+		},
+		&resp,
+	)
+	if err != nil {
+		return SchemaPlan{}, fmt.Errorf("failed to fetch schema: %w", err)
+	}
+
+	return SchemaPlan{
+		Collection: collection,
+		Changes:    diffSchema(resp.Schema, spec),
+	}, nil
+}
+
+// Apply brings the collection's schema to the spec in a single Schema API request and returns the changes.
+// Solr applies the changes of a request atomically, so none are applied if any fails.
+func (m *SchemaManager) Apply(ctx context.Context, collection string, spec SchemaSpec) (SchemaPlan, error) {
+	plan, err := m.Plan(ctx, collection, spec)
+	if err != nil || len(plan.Changes) == 0 {
+		return plan, err
+	}
+
+	var resp map[string]any
+	err = m.httpClient.Post(
+		ctx,
+		infra.PostRequest{
+			Request: infra.Request{
+				Url: fmt.Sprintf("%s/%s/schema", m.config.SolrUrl, collection),
+			},
+			Entity: schemaCommands(plan.Changes),
+		},
+		&resp,
+	)
+	if err != nil {
+		return SchemaPlan{}, fmt.Errorf("failed to update schema: %w", err)
+	}
+	return plan, nil
+}
+
+// schemaCommands encodes changes as a Schema API request, a JSON object with a key for each command.
+// Keys are repeated for commands of the same type so that the commands keep their order.
+type schemaCommands []SchemaChange
+
+// MarshalJSON implements json.Marshaler
+func (c schemaCommands) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, change := range c {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		value := change.Definition
+		if change.Action == ActionDelete {
+			// Deletions only identify the definition
+			if cf, ok := value.(CopyField); ok {
+				value = CopyField{Source: cf.Source, Dest: cf.Dest}
+			} else {
+				value = map[string]string{"name": change.Name}
+			}
+		}
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "%q:", change.Action+"-"+change.Kind)
+		buf.Write(b)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// diffSchema returns the changes from the live schema to the spec. Copy fields are deleted first and
+// added last so that they never refer to a missing field, and deleted field types come after the
+// fields that may use them.
+func diffSchema(live liveSchema, spec SchemaSpec) []SchemaChange {
+	var changes []SchemaChange
+
+	liveCopyFields := map[CopyField]CopyField{}
+	for _, cf := range live.CopyFields {
+		liveCopyFields[CopyField{Source: cf.Source, Dest: cf.Dest}] = cf
+	}
+	var addCopyFields []SchemaChange
+	for _, cf := range spec.CopyFields {
+		key := CopyField{Source: cf.Source, Dest: cf.Dest}
+		current, ok := liveCopyFields[key]
+		if ok && current == cf {
+			continue
+		}
+		if ok {
+			// Copy fields cannot be replaced, so a copy field with another maxChars is deleted and added again
+			changes = append(changes, copyFieldChange(ActionDelete, current))
+		}
+		addCopyFields = append(addCopyFields, copyFieldChange(ActionAdd, cf))
+	}
+	// Copy fields of deleted fields are deleted with them, as Solr refuses to delete fields that are copied
+	deletedFields := map[string]bool{}
+	for _, name := range slices.Concat(spec.Delete.Fields, spec.Delete.DynamicFields) {
+		deletedFields[name] = true
+	}
+	for _, cf := range live.CopyFields {
+		same := func(c CopyField) bool { return c.Source == cf.Source && c.Dest == cf.Dest }
+		if (deletedFields[cf.Source] || deletedFields[cf.Dest] || slices.ContainsFunc(spec.Delete.CopyFields, same)) &&
+			!slices.ContainsFunc(spec.CopyFields, same) {
+			changes = append(changes, copyFieldChange(ActionDelete, cf))
+		}
+	}
+
+	changes = append(changes, diffDefinitions(KindFieldType, live.FieldTypes, spec.FieldTypes)...)
+	changes = append(changes, diffDefinitions(KindField, live.Fields, spec.Fields)...)
+	changes = append(changes, diffDefinitions(KindDynamicField, live.DynamicFields, spec.DynamicFields)...)
+	changes = append(changes, addCopyFields...)
+	changes = append(changes, deleteDefinitions(KindField, live.Fields, spec.Delete.Fields)...)
+	changes = append(changes, deleteDefinitions(KindDynamicField, live.DynamicFields, spec.Delete.DynamicFields)...)
+	changes = append(changes, deleteDefinitions(KindFieldType, live.FieldTypes, spec.Delete.FieldTypes)...)
+
+	if changes == nil {
+		changes = []SchemaChange{}
+	}
+	return changes
+}
+
+// diffDefinitions returns the additions and replacements from the live definitions to the desired ones
+func diffDefinitions(kind string, live, desired []Definition) []SchemaChange {
+	current := indexDefinitions(live)
+	var changes []SchemaChange
+	for _, d := range desired {
+		c, ok := current[d.Name()]
+		switch {
+		case !ok:
+			changes = append(changes, SchemaChange{Action: ActionAdd, Kind: kind, Name: d.Name(), Definition: d})
+		case !equalDefinitions(c, d):
+			changes = append(changes, SchemaChange{Action: ActionReplace, Kind: kind, Name: d.Name(), Definition: d, Current: c})
+		}
+	}
+	return changes
+}
+
+// equalDefinitions reports whether the definitions are equal, treating the analysis components
+// declared by class, e.g. "solr.LowerCaseFilterFactory", as equal to those declared by SPI name, e.g. "lowercase"
+func equalDefinitions(a, b Definition) bool {
+	return reflect.DeepEqual(normalizeAnalyzers(a), normalizeAnalyzers(b))
+}
+
+// normalizeAnalyzers returns a copy of the definition whose analyzers declare their components by SPI name
+func normalizeAnalyzers(d Definition) Definition {
+	normalized := make(Definition, len(d))
+	for k, v := range d {
+		switch k {
+		case "analyzer", "indexAnalyzer", "queryAnalyzer", "multiTermAnalyzer":
+			v = normalizeAnalyzer(v)
+		}
+		normalized[k] = v
+	}
+	return normalized
+}
+
+// normalizeAnalyzer returns a copy of the analyzer whose components are declared by SPI name
+func normalizeAnalyzer(v any) any {
+	analyzer, ok := v.(map[string]any)
+	if !ok {
+		return v
+	}
+	normalized := make(map[string]any, len(analyzer))
+	for k, v := range analyzer {
+		switch k {
+		case "tokenizer":
+			v = normalizeComponent(v, "TokenizerFactory")
+		case "filters":
+			v = normalizeComponents(v, "FilterFactory")
+		case "charFilters":
+			v = normalizeComponents(v, "CharFilterFactory")
+		}
+		normalized[k] = v
+	}
+	return normalized
+}
+
+// normalizeComponents normalizes each analysis component of the list
+func normalizeComponents(v any, suffix string) any {
+	components, ok := v.([]any)
+	if !ok {
+		return v
+	}
+	normalized := make([]any, len(components))
+	for i, c := range components {
+		normalized[i] = normalizeComponent(c, suffix)
+	}
+	return normalized
+}
+
+// normalizeComponent returns a copy of the analysis component declared by its lowercased SPI name,
+// which Lucene looks up case-insensitively. The SPI name of a class is its simple name without the suffix
+// of its kind, e.g. "japaneseBaseForm" for "solr.JapaneseBaseFormFilterFactory".
+func normalizeComponent(v any, suffix string) any {
+	component, ok := v.(map[string]any)
+	if !ok {
+		return v
+	}
+	normalized := make(map[string]any, len(component))
+	for k, v := range component {
+		normalized[k] = v
+	}
+	if class, ok := component["class"].(string); ok && component["name"] == nil {
+		delete(normalized, "class")
+		normalized["name"] = strings.TrimSuffix(class[strings.LastIndex(class, ".")+1:], suffix)
+	}
+	if name, ok := normalized["name"].(string); ok {
+		normalized["name"] = strings.ToLower(name)
+	}
+	return normalized
+}
+
+// deleteDefinitions returns the deletions of the named definitions that exist
+func deleteDefinitions(kind string, live []Definition, names []string) []SchemaChange {
+	current := indexDefinitions(live)
+	var changes []SchemaChange
+	for _, name := range names {
+		if c, ok := current[name]; ok {
+			changes = append(changes, SchemaChange{Action: ActionDelete, Kind: kind, Name: name, Definition: c})
+		}
+	}
+	return changes
+}
+
+// indexDefinitions returns the definitions by name
+func indexDefinitions(definitions []Definition) map[string]Definition {
+	index := make(map[string]Definition, len(definitions))
+	for _, d := range definitions {
+		index[d.Name()] = d
+	}
+	return index
+}
+
+// copyFieldChange returns the change of a copy field
+func copyFieldChange(action string, cf CopyField) SchemaChange {
+	return SchemaChange{
+		Action:     action,
+		Kind:       KindCopyField,
+		Name:       cf.Source + " -> " + cf.Dest,
+		Definition: cf,
+	}
+}
+
+// normalize validates the spec and returns it with its presets expanded and its definitions
+// in the JSON representation of the Schema API, so that they compare equal to the live ones
+func (s SchemaSpec) normalize() (SchemaSpec, error) {
+	kinds := []struct {
+		kind        string
+		definitions *[]Definition
+		required    string
+	}{
+		{KindFieldType, &s.FieldTypes, "class"},
+		{KindField, &s.Fields, "type"},
+		{KindDynamicField, &s.DynamicFields, "type"},
+	}
+	for _, k := range kinds {
+		seen := map[string]bool{}
+		normalized := make([]Definition, len(*k.definitions))
+		for i, d := range *k.definitions {
+			if k.kind == KindFieldType {
+				var err error
+				if d, err = expandPreset(d); err != nil {
+					return s, err
+				}
+			}
+			name := d.Name()
+			if name == "" {
+				return s, invalidSpec("%s %d has no name", k.kind, i)
+			}
+			if seen[name] {
+				return s, invalidSpec("%s %q is defined more than once", k.kind, name)
+			}
+			seen[name] = true
+			if _, ok := d[k.required]; !ok {
+				return s, invalidSpec("%s %q has no %s", k.kind, name, k.required)
+			}
+
+			b, err := json.Marshal(d)
+			if err != nil {
+				return s, invalidSpec("%s %q cannot be encoded: %v", k.kind, name, err)
+			}
+			if err := json.Unmarshal(b, &normalized[i]); err != nil {
+				return s, invalidSpec("%s %q cannot be encoded: %v", k.kind, name, err)
+			}
+		}
+		*k.definitions = normalized
+	}
+
+	for _, cf := range slices.Concat(s.CopyFields, s.Delete.CopyFields) {
+		if cf.Source == "" || cf.Dest == "" {
+			return s, invalidSpec("copy fields need a source and a dest")
+		}
+	}
+	return s, nil
+}
+
+// expandPreset replaces the preset of a field type with its class and analyzer;
+// the other properties of the definition override those of the preset
+func expandPreset(d Definition) (Definition, error) {
+	preset, ok := d["preset"]
+	if !ok {
+		return d, nil
+	}
+	if preset != PresetKuromoji {
+		return nil, invalidSpec("unknown field type preset: %v", preset)
+	}
+	expanded := kuromojiFieldType()
+	for k, v := range d {
+		if k != "preset" {
+			expanded[k] = v
+		}
+	}
+	return expanded, nil
+}
+
+// kuromojiFieldType returns the definition of the text_ja field type of the default configset of Solr 9,
+// which declares its analysis components by SPI name. The same field type declared by class, as in
+// earlier versions, is considered equal when planning changes.
+func kuromojiFieldType() Definition {
+	return Definition{
+		"class":                     "solr.TextField",
+		"positionIncrementGap":      "100",
+		"autoGeneratePhraseQueries": "false",
+		"analyzer": map[string]any{
+			"tokenizer": map[string]any{"name": "japanese", "mode": "search"},
+			"filters": []any{
+				map[string]any{"name": "japaneseBaseForm"},
+				map[string]any{"name": "japanesePartOfSpeechStop", "tags": "lang/stoptags_ja.txt"},
+				map[string]any{"name": "cjkWidth"},
+				map[string]any{"name": "stop", "ignoreCase": "true", "words": "lang/stopwords_ja.txt"},
+				map[string]any{"name": "japaneseKatakanaStem", "minimumLength": "4"},
+				map[string]any{"name": "lowercase"},
+			},
+		},
+	}
+}
+
+// invalidSpec returns an errors.ErrBadRequest failure for an invalid schema spec
+func invalidSpec(format string, args ...any) error {
+	return failure.New(
+		errors.ErrBadRequest,
+		failure.Field(failure.Messagef("invalid schema spec: "+format, args...)),
+	)
+}
@@ -0,0 +1,244 @@
+package solr
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/errors"
+	"github.com/takatori/skg/internal/infra"
+)
+
+// liveTestSchema is the schema of a collection returned by the fake Schema API
+const liveTestSchema = `{"schema":{
+	"fieldTypes":[{"name":"string","class":"solr.StrField"},{"name":"text_old","class":"solr.TextField"}],
+	"fields":[
+		{"name":"id","type":"string","required":true},
+		{"name":"title","type":"string","stored":true},
+		{"name":"legacy","type":"string"}
+	],
+	"dynamicFields":[{"name":"*_s","type":"string"}],
+	"copyFields":[{"source":"title","dest":"legacy"},{"source":"title","dest":"id","maxChars":10}]
+}}`
+
+// parseSchemaSpec decodes a schema spec from its JSON representation
+func parseSchemaSpec(t *testing.T, spec string) SchemaSpec {
+	t.Helper()
+	var s SchemaSpec
+	if err := json.Unmarshal([]byte(spec), &s); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	return s
+}
+
+// changeNames returns the "<action>-<kind> <name>" of each change
+func changeNames(changes []SchemaChange) []string {
+	names := make([]string, len(changes))
+	for i, c := range changes {
+		names[i] = c.Action + "-" + c.Kind + " " + c.Name
+	}
+	return names
+}
+
+func TestDiffSchema(t *testing.T) {
+	var live struct {
+		Schema liveSchema `json:"schema"`
+	}
+	if err := json.Unmarshal([]byte(liveTestSchema), &live); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		spec     string
+		expected []string
+	}{
+		{
+			name:     "unchanged",
+			spec:     `{"fields":[{"name":"title","type":"string","stored":true}],"copyFields":[{"source":"title","dest":"legacy"}]}`,
+			expected: []string{},
+		},
+		{
+			name: "add and replace",
+			spec: `{
+				"fieldTypes":[{"name":"text_ja","preset":"kuromoji"}],
+				"fields":[{"name":"title","type":"text_ja","stored":true},{"name":"body","type":"text_ja"}],
+				"dynamicFields":[{"name":"*_s","type":"string"},{"name":"*_i","type":"pint"}],
+				"copyFields":[{"source":"title","dest":"body"},{"source":"title","dest":"id","maxChars":20}]
+			}`,
+			expected: []string{
+				"delete-copy-field title -> id",
+				"add-field-type text_ja",
+				"replace-field title",
+				"add-field body",
+				"add-dynamic-field *_i",
+				"add-copy-field title -> body",
+				"add-copy-field title -> id",
+			},
+		},
+		{
+			name: "delete",
+			spec: `{"delete":{"fields":["legacy","missing"],"dynamicFields":["*_s"],"fieldTypes":["text_old"],"copyFields":[{"source":"title","dest":"id"}]}}`,
+			expected: []string{
+				"delete-copy-field title -> legacy",
+				"delete-copy-field title -> id",
+				"delete-field legacy",
+				"delete-dynamic-field *_s",
+				"delete-field-type text_old",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec, err := parseSchemaSpec(t, test.spec).normalize()
+			if err != nil {
+				t.Fatalf("normalize() error = %v", err)
+			}
+			changes := changeNames(diffSchema(live.Schema, spec))
+			if !reflect.DeepEqual(changes, test.expected) {
+				t.Errorf("diffSchema() = %v, expected %v", changes, test.expected)
+			}
+		})
+	}
+}
+
+func TestDiffSchemaAnalyzers(t *testing.T) {
+	// textJa returns a text_ja field type with the analysis components
+	textJa := func(name, tokenizer, filters string) string {
+		return `{"name":"` + name + `","class":"solr.TextField","positionIncrementGap":"100","autoGeneratePhraseQueries":"false",` +
+			`"analyzer":{"tokenizer":` + tokenizer + `,"filters":[` + filters + `]}}`
+	}
+	// text_ja as returned by the Schema API for the default configset of Solr 9, declared by SPI name
+	solr9 := textJa("text_ja", `{"name":"japanese","mode":"search"}`, `
+		{"name":"japaneseBaseForm"},
+		{"name":"japanesePartOfSpeechStop","tags":"lang/stoptags_ja.txt"},
+		{"name":"cjkWidth"},
+		{"name":"stop","ignoreCase":"true","words":"lang/stopwords_ja.txt"},
+		{"name":"japaneseKatakanaStem","minimumLength":"4"},
+		{"name":"lowercase"}`)
+	// text_ja as declared by class in earlier versions
+	solr8 := textJa("text_ja_8", `{"class":"solr.JapaneseTokenizerFactory","mode":"search"}`, `
+		{"class":"solr.JapaneseBaseFormFilterFactory"},
+		{"class":"solr.JapanesePartOfSpeechStopFilterFactory","tags":"lang/stoptags_ja.txt"},
+		{"class":"solr.CJKWidthFilterFactory"},
+		{"class":"solr.StopFilterFactory","ignoreCase":"true","words":"lang/stopwords_ja.txt"},
+		{"class":"solr.JapaneseKatakanaStemFilterFactory","minimumLength":"4"},
+		{"class":"solr.LowerCaseFilterFactory"}`)
+	changed := textJa("text_ja_5", `{"name":"japanese","mode":"search"}`, `
+		{"name":"japaneseBaseForm"},
+		{"name":"japanesePartOfSpeechStop","tags":"lang/stoptags_ja.txt"},
+		{"name":"cjkWidth"},
+		{"name":"stop","ignoreCase":"true","words":"lang/stopwords_ja.txt"},
+		{"name":"japaneseKatakanaStem","minimumLength":"5"},
+		{"name":"lowercase"}`)
+
+	var live struct {
+		Schema liveSchema `json:"schema"`
+	}
+	if err := json.Unmarshal([]byte(`{"schema":{"fieldTypes":[`+solr9+`,`+solr8+`,`+changed+`]}}`), &live); err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := parseSchemaSpec(t, `{"fieldTypes":[
+		{"name":"text_ja","preset":"kuromoji"},
+		{"name":"text_ja_8","preset":"kuromoji"},
+		{"name":"text_ja_5","preset":"kuromoji"}
+	]}`).normalize()
+	if err != nil {
+		t.Fatalf("normalize() error = %v", err)
+	}
+	changes := changeNames(diffSchema(live.Schema, spec))
+	if expected := []string{"replace-field-type text_ja_5"}; !reflect.DeepEqual(changes, expected) {
+		t.Errorf("diffSchema() = %v, expected %v", changes, expected)
+	}
+}
+
+func TestSchemaSpecNormalizeInvalid(t *testing.T) {
+	tests := []string{
+		`{"fields":[{"type":"string"}]}`,
+		`{"fields":[{"name":"title"}]}`,
+		`{"fields":[{"name":"title","type":"string"},{"name":"title","type":"text_ja"}]}`,
+		`{"fieldTypes":[{"name":"text_ja"}]}`,
+		`{"fieldTypes":[{"name":"text_ja","preset":"sudachi"}]}`,
+		`{"copyFields":[{"source":"title"}]}`,
+	}
+
+	for _, spec := range tests {
+		if _, err := parseSchemaSpec(t, spec).normalize(); !failure.Is(err, errors.ErrBadRequest) {
+			t.Errorf("normalize(%s) error = %v, expected %s", spec, err, errors.ErrBadRequest)
+		}
+	}
+}
+
+func TestSchemaCommandsMarshalJSON(t *testing.T) {
+	changes := []SchemaChange{
+		copyFieldChange(ActionDelete, CopyField{Source: "title", Dest: "id", MaxChars: 10}),
+		{Action: ActionAdd, Kind: KindFieldType, Name: "text_ja", Definition: Definition{"name": "text_ja", "class": "solr.TextField"}},
+		{Action: ActionAdd, Kind: KindField, Name: "body", Definition: Definition{"name": "body", "type": "text_ja"}},
+		{Action: ActionAdd, Kind: KindField, Name: "summary", Definition: Definition{"name": "summary", "type": "text_ja"}},
+		{Action: ActionDelete, Kind: KindField, Name: "legacy", Definition: Definition{"name": "legacy", "type": "string"}},
+	}
+
+	b, err := json.Marshal(schemaCommands(changes))
+	if err != nil {
+		t.Fatalf("MarshalJSON() error = %v", err)
+	}
+	expected := `{"delete-copy-field":{"source":"title","dest":"id"},` +
+		`"add-field-type":{"class":"solr.TextField","name":"text_ja"},` +
+		`"add-field":{"name":"body","type":"text_ja"},` +
+		`"add-field":{"name":"summary","type":"text_ja"},` +
+		`"delete-field":{"name":"legacy"}}`
+	if string(b) != expected {
+		t.Errorf("MarshalJSON() = %s, expected %s", b, expected)
+	}
+}
+
+func TestSchemaManagerApply(t *testing.T) {
+	var posted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/products/schema" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			posted = append(posted, string(body))
+			_, _ = w.Write([]byte(`{"responseHeader":{"status":0}}`))
+			return
+		}
+		_, _ = w.Write([]byte(liveTestSchema))
+	}))
+	defer server.Close()
+
+	config := &internal.Config{SolrUrl: server.URL}
+	httpClient, err := infra.NewHttpClient(config)
+	if err != nil {
+		t.Fatalf("NewHttpClient() error = %v", err)
+	}
+	manager := NewSchemaManager(config, httpClient)
+	ctx := context.Background()
+
+	plan, err := manager.Apply(ctx, "products", parseSchemaSpec(t, `{"fields":[{"name":"body","type":"string"}]}`))
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(plan.Changes) != 1 || len(posted) != 1 || posted[0] != `{"add-field":{"name":"body","type":"string"}}` {
+		t.Errorf("Apply() = %+v, posted %v, expected the field to be added", plan, posted)
+	}
+
+	// A spec matching the schema sends no commands
+	posted = nil
+	plan, err = manager.Apply(ctx, "products", parseSchemaSpec(t, `{"fields":[{"name":"title","type":"string","stored":true}]}`))
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(plan.Changes) != 0 || len(posted) != 0 {
+		t.Errorf("Apply() = %+v, posted %v, expected no changes", plan, posted)
+	}
+}
@@ -0,0 +1,83 @@
+package solr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/morikuni/failure/v2"
+	"github.com/takatori/skg/internal"
+	"github.com/takatori/skg/internal/errors"
+	"github.com/takatori/skg/internal/infra"
+)
+
+func TestMatchDynamicField(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		field    string
+		expected bool
+	}{
+		{"*_txt_ja", "body_txt_ja", true},
+		{"*_txt_ja", "_txt_ja", false},
+		{"*_txt_ja", "body_txt", false},
+		{"attr_*", "attr_color", true},
+		{"attr_*", "attr_", false},
+		{"text", "text", true},
+	}
+
+	for _, test := range tests {
+		if result := matchDynamicField(test.pattern, test.field); result != test.expected {
+			t.Errorf("matchDynamicField(%q, %q) = %v, expected %v", test.pattern, test.field, result, test.expected)
+		}
+	}
+}
+
+func TestValidateFields(t *testing.T) {
+	requests := 0
+	fields := `{"name":"text"},{"name":"title_ja"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/products/schema" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"schema":{"fields":[` + fields + `],"dynamicFields":[{"name":"*_s"}]}}`))
+	}))
+	defer server.Close()
+
+	config := &internal.Config{SolrUrl: server.URL, SchemaCacheTTL: time.Minute}
+	httpClient, err := infra.NewHttpClient(config)
+	if err != nil {
+		t.Fatalf("NewHttpClient() error = %v", err)
+	}
+	inspector := NewSchemaInspector(config, httpClient)
+	ctx := context.Background()
+
+	if err := inspector.ValidateFields(ctx, "products", "text", "title_ja", "brand_s"); err != nil {
+		t.Errorf("ValidateFields() error = %v", err)
+	}
+	if err := inspector.ValidateFields(ctx, "products", "text"); err != nil {
+		t.Errorf("ValidateFields() error = %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected the schema to be fetched once, got %d", requests)
+	}
+
+	// A field added after the schema was cached is found by fetching the schema again
+	fields += `,{"name":"body"}`
+	if err := inspector.ValidateFields(ctx, "products", "text", "body"); err != nil {
+		t.Errorf("ValidateFields() error = %v for a field added to the schema", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected the schema to be fetched again, got %d requests", requests)
+	}
+
+	err = inspector.ValidateFields(ctx, "products", "text", "missing")
+	if !failure.Is(err, errors.ErrBadRequest) {
+		t.Errorf("ValidateFields() error = %v, expected %s", err, errors.ErrBadRequest)
+	}
+	if requests != 3 {
+		t.Errorf("Expected the schema to be fetched once more for the unknown field, got %d requests", requests)
+	}
+}
@@ -9,6 +9,9 @@ import (
 	"github.com/takatori/skg/internal/skg"
 )
 
+// DefaultCollection is the collection traversed when none is given
+const DefaultCollection = "products"
+
 type SolrSemanticKnowledgeGraph struct {
 	config     *internal.Config
 	httpClient *infra.HttpClient
@@ -16,11 +19,15 @@ type SolrSemanticKnowledgeGraph struct {
 
 // NewSolrSemanticKnowledgeGraph creates a new SolrSemanticKnowledgeGraph with the given config
 // and initializes the HTTP client
-func NewSolrSemanticKnowledgeGraph(config *internal.Config) *SolrSemanticKnowledgeGraph {
+func NewSolrSemanticKnowledgeGraph(config *internal.Config) (*SolrSemanticKnowledgeGraph, error) {
+	httpClient, err := infra.NewHttpClient(config)
+	if err != nil {
+		return nil, err
+	}
 	return &SolrSemanticKnowledgeGraph{
 		config:     config,
-		httpClient: infra.NewHttpClient(),
-	}
+		httpClient: httpClient,
+	}, nil
 }
 
 // NewSolrSemanticKnowledgeGraphWithClient creates a new SolrSemanticKnowledgeGraph with the given config
@@ -32,13 +39,13 @@ func NewSolrSemanticKnowledgeGraphWithClient(config *internal.Config, httpClient
 	}
 }
 
-func (s *SolrSemanticKnowledgeGraph) Traverse(ctx context.Context, q [][]skg.Query, collection string) (map[string]skg.Traversal, error) {
+func (s *SolrSemanticKnowledgeGraph) Traverse(ctx context.Context, q [][]skg.Query, collection string, opts skg.TraverseOptions) ([]skg.Traversal, error) {
 	// Use default collection if none provided
 	if collection == "" {
-		collection = "products"
+		collection = DefaultCollection
 	}
 
-	reqBody := transformRequest(q)
+	reqBody := transformRequest(q, opts)
 	url := fmt.Sprintf("%s/%s/query", s.config.SolrUrl, collection)
 
 	// Create a response map to hold the Solr response
@@ -53,6 +60,8 @@ func (s *SolrSemanticKnowledgeGraph) Traverse(ctx context.Context, q [][]skg.Que
 				Headers: map[string]string{
 					"Content-Type": "application/json",
 				},
+				// Traversals only read the index
+				Idempotent: true,
 			},
 			Entity: reqBody,
 		},
@@ -64,6 +73,7 @@ func (s *SolrSemanticKnowledgeGraph) Traverse(ctx context.Context, q [][]skg.Que
 	node := solrResp["facets"].(map[string]interface{})
 	converter := ResponseConverter{
 		RequestParams: reqBody["params"].(map[string]interface{}),
+		Queries:       q,
 	}
-	return converter.transformResponseFacet(node), nil
+	return converter.transformResponseFacet(node, 0), nil
 }
@@ -0,0 +1,85 @@
+package skg
+
+import (
+	"cmp"
+	"slices"
+)
+
+// SortField selects how the values of a traversal are ordered.
+//
+// When a query has no SortField, terms facets are ordered by relatedness descending
+// and query facets keep the order of the query's Values.
+// When a SortField is set, values are additionally sorted after decoding, with ties
+// broken by key, so that the output is stable across calls.
+type SortField string
+
+const (
+	SortByRelatedness SortField = "relatedness"
+	SortByCount       SortField = "count"
+	SortByKey         SortField = "key"
+)
+
+// SortDirection is the direction of a sort.
+type SortDirection string
+
+const (
+	Ascending  SortDirection = "asc"
+	Descending SortDirection = "desc"
+)
+
+// IsValid reports whether the sort field is empty or one of the known fields.
+func (f SortField) IsValid() bool {
+	switch f {
+	case "", SortByRelatedness, SortByCount, SortByKey:
+		return true
+	}
+	return false
+}
+
+// IsValid reports whether the sort direction is empty or one of the known directions.
+func (d SortDirection) IsValid() bool {
+	switch d {
+	case "", Ascending, Descending:
+		return true
+	}
+	return false
+}
+
+// DefaultSortDirection returns the direction or the natural direction of the field if empty:
+// ascending for keys and descending for relatedness and count.
+func DefaultSortDirection(field SortField, direction SortDirection) SortDirection {
+	if direction != "" {
+		return direction
+	}
+	if field == SortByKey {
+		return Ascending
+	}
+	return Descending
+}
+
+// SortNodes sorts nodes in place by the given field and direction, breaking ties by key.
+func SortNodes(nodes []Node, field SortField, direction SortDirection) {
+	if field == "" {
+		field = SortByRelatedness
+	}
+	direction = DefaultSortDirection(field, direction)
+
+	slices.SortStableFunc(nodes, func(a, b Node) int {
+		var c int
+		switch field {
+		case SortByCount:
+			c = cmp.Compare(a.Count, b.Count)
+		case SortByKey:
+			c = cmp.Compare(a.Key, b.Key)
+		default:
+			c = cmp.Compare(a.Relatedness, b.Relatedness)
+		}
+		if direction == Descending {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Key, b.Key)
+	})
+}
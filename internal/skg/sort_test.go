@@ -0,0 +1,42 @@
+package skg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortNodes(t *testing.T) {
+	nodes := []Node{
+		{Key: "b", Relatedness: 0.5, Count: 10},
+		{Key: "c", Relatedness: 0.9, Count: 1},
+		{Key: "a", Relatedness: 0.5, Count: 30},
+	}
+
+	tests := []struct {
+		name      string
+		field     SortField
+		direction SortDirection
+		expected  []string
+	}{
+		{"default", "", "", []string{"c", "a", "b"}},
+		{"relatedness ascending", SortByRelatedness, Ascending, []string{"a", "b", "c"}},
+		{"count", SortByCount, "", []string{"a", "b", "c"}},
+		{"key", SortByKey, "", []string{"a", "b", "c"}},
+		{"key descending", SortByKey, Descending, []string{"c", "b", "a"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sorted := append([]Node(nil), nodes...)
+			SortNodes(sorted, test.field, test.direction)
+
+			var keys []string
+			for _, n := range sorted {
+				keys = append(keys, n.Key)
+			}
+			if !reflect.DeepEqual(keys, test.expected) {
+				t.Errorf("SortNodes(%q, %q) = %v, expected %v", test.field, test.direction, keys, test.expected)
+			}
+		})
+	}
+}